@@ -0,0 +1,18 @@
+// gen-schema writes the JSON Schema of the plugin config to stdout
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/plugin"
+)
+
+func main() {
+	schema, err := plugin.ConfigJSONSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate schema: %s\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(schema)
+}
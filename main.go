@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/metrics"
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/plugin"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/tracing"
 
 	rolloutsPlugin "github.com/argoproj/argo-rollouts/rollout/trafficrouting/plugin/rpc"
 	goPlugin "github.com/hashicorp/go-plugin"
@@ -20,10 +26,39 @@ var handshakeConfig = goPlugin.HandshakeConfig{
 
 func main() {
 	logCtx := log.WithFields(log.Fields{"plugin": "trafficrouter"})
-	log.SetLevel(log.DebugLevel)
+
+	settings, err := config.FromEnv()
+	if err != nil {
+		logCtx.Fatalf("invalid plugin settings: %s", err)
+	}
+	log.SetLevel(settings.LogLevel)
+	if settings.LogFormat == config.LogFormatJSON {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	if settings.MetricsPort != 0 {
+		go func() {
+			if err := metrics.Serve(fmt.Sprintf(":%d", settings.MetricsPort)); err != nil {
+				logCtx.Errorf("failed to serve metrics: %s", err)
+			}
+		}()
+	}
+
+	if settings.OTLPEndpoint != "" {
+		shutdown, err := tracing.Init(context.Background(), settings.OTLPEndpoint, settings.OTLPInsecure, plugin.Version)
+		if err != nil {
+			logCtx.Fatalf("failed to initialize tracing: %s", err)
+		}
+		defer func() {
+			if err := shutdown(context.Background()); err != nil {
+				logCtx.Errorf("failed to flush traces: %s", err)
+			}
+		}()
+	}
 
 	rpcPluginImp := &plugin.RpcPlugin{
-		LogCtx: logCtx,
+		LogCtx:   logCtx,
+		Settings: settings,
 	}
 
 	var pluginMap = map[string]goPlugin.Plugin{
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Argo Rollouts does not pass arguments to traffic router plugins, but the plugin process inherits the
+// environment of the Argo Rollouts controller, so plugin-wide settings are read from there.
+const (
+	EnvKubeConfig          = "GLOO_PLUGIN_KUBECONFIG"
+	EnvKubeConfigSecret    = "GLOO_PLUGIN_KUBECONFIG_SECRET"
+	EnvKubeConfigSecretKey = "GLOO_PLUGIN_KUBECONFIG_SECRET_KEY"
+	EnvKubeConfigDir       = "GLOO_PLUGIN_KUBECONFIG_DIR"
+
+	EnvRouteTableCache              = "GLOO_PLUGIN_ROUTETABLE_CACHE"
+	EnvRouteTableCacheNamespaces    = "GLOO_PLUGIN_ROUTETABLE_CACHE_NAMESPACES"
+	EnvRouteTableCacheLabelSelector = "GLOO_PLUGIN_ROUTETABLE_CACHE_LABEL_SELECTOR"
+
+	EnvRouteTableEvents = "GLOO_PLUGIN_ROUTETABLE_EVENTS"
+
+	EnvRequestTimeout = "GLOO_PLUGIN_REQUEST_TIMEOUT"
+	EnvClientQPS      = "GLOO_PLUGIN_CLIENT_QPS"
+	EnvClientBurst    = "GLOO_PLUGIN_CLIENT_BURST"
+
+	EnvMetricsPort = "GLOO_PLUGIN_METRICS_PORT"
+
+	EnvLogLevel  = "GLOO_PLUGIN_LOG_LEVEL"
+	EnvLogFormat = "GLOO_PLUGIN_LOG_FORMAT"
+
+	EnvOTLPEndpoint = "GLOO_PLUGIN_OTLP_ENDPOINT"
+	EnvOTLPInsecure = "GLOO_PLUGIN_OTLP_INSECURE"
+
+	EnvDriftAction = "GLOO_PLUGIN_DRIFT_ACTION"
+
+	EnvDryRun = "GLOO_PLUGIN_DRY_RUN"
+
+	EnvPatchConcurrency = "GLOO_PLUGIN_PATCH_CONCURRENCY"
+
+	EnvConfigDefaults = "GLOO_PLUGIN_CONFIG_DEFAULTS"
+
+	EnvClusterName = "GLOO_PLUGIN_CLUSTER_NAME"
+
+	DefaultRequestTimeout = 30 * time.Second
+
+	DefaultKubeConfigSecretKey = "kubeconfig"
+
+	DefaultLogLevel = logrus.InfoLevel
+
+	DefaultPatchConcurrency = 10
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// actions taken when the weights of a RouteTable no longer match the weights the plugin last applied
+const (
+	// apply the desired weights again
+	DriftActionReapply = "reapply"
+	// leave the RouteTable as is and report the drift through VerifyWeight
+	DriftActionVerify = "verify"
+	// fail SetWeight
+	DriftActionFail = "fail"
+)
+
+// ValidDriftAction returns true if action is a known drift action
+func ValidDriftAction(action string) bool {
+	switch action {
+	case DriftActionReapply, DriftActionVerify, DriftActionFail:
+		return true
+	}
+	return false
+}
+
+// Settings are plugin-wide settings shared by every Rollout using the plugin
+type Settings struct {
+	// path to a kubeconfig for the cluster hosting Gloo Platform RouteTables (e.g. the management cluster)
+	KubeConfigPath string
+	// Secret containing a kubeconfig for the cluster hosting Gloo Platform RouteTables
+	KubeConfigSecret *SecretKeyRef
+	// directory holding the kubeconfigs Rollouts may refer to with a kubeConfigPath; Rollouts may not refer to
+	// kubeconfig files if empty
+	KubeConfigDir string
+	// serve RouteTable reads from a shared informer; nil when disabled
+	RouteTableCache *RouteTableCacheSettings
+	// record weight change Events on RouteTables in addition to Rollouts
+	RouteTableEvents bool
+	// deadline for each plugin call; also bounds each request to the API server
+	RequestTimeout time.Duration
+	// client-side rate limit of the Gloo client; client-go defaults apply if zero
+	ClientQPS   float32
+	ClientBurst int
+	// port serving Prometheus metrics; metrics are not served if zero
+	MetricsPort int
+	// level and format (text or json) of the plugin logs
+	LogLevel  logrus.Level
+	LogFormat string
+	// OTLP gRPC collector (host:port) receiving traces; tracing is disabled if empty
+	OTLPEndpoint string
+	// connect to OTLPEndpoint without TLS
+	OTLPInsecure bool
+	// default action taken on weight drift; see DriftActionReapply, DriftActionVerify and DriftActionFail
+	DriftAction string
+	// compute and record RouteTable patches without sending them
+	DryRun bool
+	// maximum number of RouteTables updated concurrently by a single plugin call
+	PatchConcurrency int
+	// ConfigMap holding defaults of the plugin config of every Rollout
+	ConfigDefaults *ObjectRef
+	// name of the cluster running the Rollouts, telling apart Rollouts of different clusters sharing RouteTables
+	ClusterName string
+}
+
+// RouteTableCacheSettings scope the RouteTable informer
+type RouteTableCacheSettings struct {
+	// namespaces to watch; all namespaces if empty
+	Namespaces []string
+	// RouteTables to watch; all RouteTables if nil
+	LabelSelector labels.Selector
+}
+
+// SecretKeyRef refers to a key within a Secret
+type SecretKeyRef struct {
+	Name      string
+	Namespace string
+	Key       string
+}
+
+// ObjectRef refers to a namespaced object
+type ObjectRef struct {
+	Name      string
+	Namespace string
+}
+
+// FromEnv builds Settings from the plugin process environment
+func FromEnv() (*Settings, error) {
+	s := &Settings{
+		KubeConfigPath:   os.Getenv(EnvKubeConfig),
+		KubeConfigDir:    os.Getenv(EnvKubeConfigDir),
+		RequestTimeout:   DefaultRequestTimeout,
+		LogLevel:         DefaultLogLevel,
+		LogFormat:        LogFormatText,
+		OTLPEndpoint:     os.Getenv(EnvOTLPEndpoint),
+		ClusterName:      os.Getenv(EnvClusterName),
+		DriftAction:      DriftActionReapply,
+		PatchConcurrency: DefaultPatchConcurrency,
+	}
+
+	if v := os.Getenv(EnvKubeConfigSecret); v != "" {
+		parts := strings.Split(v, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s must be in the form namespace/name; got %q", EnvKubeConfigSecret, v)
+		}
+		s.KubeConfigSecret = &SecretKeyRef{
+			Namespace: parts[0],
+			Name:      parts[1],
+			Key:       DefaultKubeConfigSecretKey,
+		}
+		if key := os.Getenv(EnvKubeConfigSecretKey); key != "" {
+			s.KubeConfigSecret.Key = key
+		}
+	}
+
+	if v := os.Getenv(EnvRouteTableCache); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvRouteTableCache, err)
+		}
+		if enabled {
+			s.RouteTableCache = &RouteTableCacheSettings{}
+			for _, ns := range strings.Split(os.Getenv(EnvRouteTableCacheNamespaces), ",") {
+				if ns = strings.TrimSpace(ns); ns != "" {
+					s.RouteTableCache.Namespaces = append(s.RouteTableCache.Namespaces, ns)
+				}
+			}
+			if sel := os.Getenv(EnvRouteTableCacheLabelSelector); sel != "" {
+				selector, err := labels.Parse(sel)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s: %s", EnvRouteTableCacheLabelSelector, err)
+				}
+				s.RouteTableCache.LabelSelector = selector
+			}
+		}
+	}
+
+	if v := os.Getenv(EnvRouteTableEvents); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvRouteTableEvents, err)
+		}
+		s.RouteTableEvents = enabled
+	}
+
+	if v := os.Getenv(EnvRequestTimeout); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvRequestTimeout, err)
+		}
+		s.RequestTimeout = timeout
+	}
+	if v := os.Getenv(EnvClientQPS); v != "" {
+		qps, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvClientQPS, err)
+		}
+		s.ClientQPS = float32(qps)
+	}
+	if v := os.Getenv(EnvClientBurst); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvClientBurst, err)
+		}
+		s.ClientBurst = burst
+	}
+
+	if v := os.Getenv(EnvMetricsPort); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvMetricsPort, err)
+		}
+		if port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid %s: %d is not a valid port", EnvMetricsPort, port)
+		}
+		s.MetricsPort = port
+	}
+
+	if v := os.Getenv(EnvLogLevel); v != "" {
+		level, err := logrus.ParseLevel(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvLogLevel, err)
+		}
+		s.LogLevel = level
+	}
+	if v := os.Getenv(EnvLogFormat); v != "" {
+		switch strings.ToLower(v) {
+		case LogFormatText, LogFormatJSON:
+			s.LogFormat = strings.ToLower(v)
+		default:
+			return nil, fmt.Errorf("invalid %s: must be %s or %s; got %q", EnvLogFormat, LogFormatText, LogFormatJSON, v)
+		}
+	}
+
+	if v := os.Getenv(EnvOTLPInsecure); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvOTLPInsecure, err)
+		}
+		s.OTLPInsecure = insecure
+	}
+
+	if v := os.Getenv(EnvDriftAction); v != "" {
+		if !ValidDriftAction(v) {
+			return nil, fmt.Errorf("invalid %s: must be one of %s, %s or %s; got %q", EnvDriftAction, DriftActionReapply, DriftActionVerify, DriftActionFail, v)
+		}
+		s.DriftAction = v
+	}
+
+	if v := os.Getenv(EnvDryRun); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvDryRun, err)
+		}
+		s.DryRun = dryRun
+	}
+
+	if v := os.Getenv(EnvPatchConcurrency); v != "" {
+		concurrency, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", EnvPatchConcurrency, err)
+		}
+		if concurrency < 1 {
+			return nil, fmt.Errorf("invalid %s: must be at least 1; got %d", EnvPatchConcurrency, concurrency)
+		}
+		s.PatchConcurrency = concurrency
+	}
+
+	if v := os.Getenv(EnvConfigDefaults); v != "" {
+		parts := strings.Split(v, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s must be in the form namespace/name; got %q", EnvConfigDefaults, v)
+		}
+		s.ConfigDefaults = &ObjectRef{
+			Namespace: parts[0],
+			Name:      parts[1],
+		}
+	}
+
+	if strings.Contains(s.ClusterName, "/") {
+		return nil, fmt.Errorf("invalid %s: must not contain '/'; got %q", EnvClusterName, s.ClusterName)
+	}
+
+	if s.KubeConfigPath != "" && s.KubeConfigSecret != nil {
+		return nil, fmt.Errorf("only one of %s and %s may be set", EnvKubeConfig, EnvKubeConfigSecret)
+	}
+
+	return s, nil
+}
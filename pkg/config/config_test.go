@@ -0,0 +1,181 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var envs = []string{
+	EnvKubeConfig, EnvKubeConfigSecret, EnvKubeConfigSecretKey, EnvKubeConfigDir,
+	EnvRouteTableCache, EnvRouteTableCacheNamespaces, EnvRouteTableCacheLabelSelector,
+	EnvRouteTableEvents,
+	EnvRequestTimeout, EnvClientQPS, EnvClientBurst,
+	EnvMetricsPort,
+	EnvLogLevel, EnvLogFormat,
+	EnvOTLPEndpoint, EnvOTLPInsecure,
+	EnvDriftAction,
+	EnvDryRun,
+	EnvPatchConcurrency,
+	EnvConfigDefaults,
+	EnvClusterName,
+}
+
+// setEnv sets the plugin environment to env; FromEnv treats empty variables as unset
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range envs {
+		t.Setenv(name, env[name])
+	}
+}
+
+func defaults() *Settings {
+	return &Settings{
+		RequestTimeout:   DefaultRequestTimeout,
+		LogLevel:         DefaultLogLevel,
+		LogFormat:        LogFormatText,
+		DriftAction:      DriftActionReapply,
+		PatchConcurrency: DefaultPatchConcurrency,
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		settings func(s *Settings)
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "kubeconfig secret",
+			env:  map[string]string{EnvKubeConfigSecret: "argo-rollouts/gloo-mgmt"},
+			settings: func(s *Settings) {
+				s.KubeConfigSecret = &SecretKeyRef{Namespace: "argo-rollouts", Name: "gloo-mgmt", Key: DefaultKubeConfigSecretKey}
+			},
+		},
+		{
+			name: "kubeconfig secret key",
+			env:  map[string]string{EnvKubeConfigSecret: "argo-rollouts/gloo-mgmt", EnvKubeConfigSecretKey: "config"},
+			settings: func(s *Settings) {
+				s.KubeConfigSecret = &SecretKeyRef{Namespace: "argo-rollouts", Name: "gloo-mgmt", Key: "config"}
+			},
+		},
+		{
+			name: "route table cache",
+			env: map[string]string{
+				EnvRouteTableCache:              "true",
+				EnvRouteTableCacheNamespaces:    " gloo-mesh, ,apps ",
+				EnvRouteTableCacheLabelSelector: "rollouts=enabled",
+			},
+			settings: func(s *Settings) {
+				s.RouteTableCache = &RouteTableCacheSettings{
+					Namespaces:    []string{"gloo-mesh", "apps"},
+					LabelSelector: labels.SelectorFromSet(labels.Set{"rollouts": "enabled"}),
+				}
+			},
+		},
+		{
+			name: "route table cache disabled",
+			env:  map[string]string{EnvRouteTableCache: "false", EnvRouteTableCacheNamespaces: "gloo-mesh"},
+		},
+		{
+			name: "durations and numbers",
+			env: map[string]string{
+				EnvRequestTimeout:   "1m30s",
+				EnvClientQPS:        "12.5",
+				EnvClientBurst:      "20",
+				EnvMetricsPort:      "9090",
+				EnvPatchConcurrency: "1",
+			},
+			settings: func(s *Settings) {
+				s.RequestTimeout = 90 * time.Second
+				s.ClientQPS = 12.5
+				s.ClientBurst = 20
+				s.MetricsPort = 9090
+				s.PatchConcurrency = 1
+			},
+		},
+		{
+			name: "logging",
+			env:  map[string]string{EnvLogLevel: "DEBUG", EnvLogFormat: "JSON"},
+			settings: func(s *Settings) {
+				s.LogLevel = logrus.DebugLevel
+				s.LogFormat = LogFormatJSON
+			},
+		},
+		{
+			name: "flags and names",
+			env: map[string]string{
+				EnvKubeConfig:       "/etc/gloo/kubeconfig",
+				EnvKubeConfigDir:    "/etc/gloo",
+				EnvRouteTableEvents: "1",
+				EnvOTLPEndpoint:     "otel-collector:4317",
+				EnvOTLPInsecure:     "true",
+				EnvDriftAction:      DriftActionVerify,
+				EnvDryRun:           "true",
+				EnvConfigDefaults:   "argo-rollouts/gloo-plugin-defaults",
+				EnvClusterName:      "east",
+			},
+			settings: func(s *Settings) {
+				s.KubeConfigPath = "/etc/gloo/kubeconfig"
+				s.KubeConfigDir = "/etc/gloo"
+				s.RouteTableEvents = true
+				s.OTLPEndpoint = "otel-collector:4317"
+				s.OTLPInsecure = true
+				s.DriftAction = DriftActionVerify
+				s.DryRun = true
+				s.ConfigDefaults = &ObjectRef{Namespace: "argo-rollouts", Name: "gloo-plugin-defaults"}
+				s.ClusterName = "east"
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			setEnv(t, tc.env)
+			expected := defaults()
+			if tc.settings != nil {
+				tc.settings(expected)
+			}
+			s, err := FromEnv()
+			assert.NoError(t, err)
+			assert.Equal(t, expected, s)
+		})
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		env map[string]string
+		err string
+	}{
+		{map[string]string{EnvKubeConfigSecret: "gloo-mgmt"}, `GLOO_PLUGIN_KUBECONFIG_SECRET must be in the form namespace/name; got "gloo-mgmt"`},
+		{map[string]string{EnvKubeConfigSecret: "argo-rollouts/"}, `GLOO_PLUGIN_KUBECONFIG_SECRET must be in the form namespace/name; got "argo-rollouts/"`},
+		{map[string]string{EnvRouteTableCache: "yes"}, `invalid GLOO_PLUGIN_ROUTETABLE_CACHE: strconv.ParseBool: parsing "yes": invalid syntax`},
+		{map[string]string{EnvRouteTableCache: "true", EnvRouteTableCacheLabelSelector: "a b"}, "invalid GLOO_PLUGIN_ROUTETABLE_CACHE_LABEL_SELECTOR: "},
+		{map[string]string{EnvRouteTableEvents: "on"}, `invalid GLOO_PLUGIN_ROUTETABLE_EVENTS: strconv.ParseBool: parsing "on": invalid syntax`},
+		{map[string]string{EnvRequestTimeout: "30"}, `invalid GLOO_PLUGIN_REQUEST_TIMEOUT: time: missing unit in duration "30"`},
+		{map[string]string{EnvClientQPS: "fast"}, `invalid GLOO_PLUGIN_CLIENT_QPS: strconv.ParseFloat: parsing "fast": invalid syntax`},
+		{map[string]string{EnvClientBurst: "1.5"}, `invalid GLOO_PLUGIN_CLIENT_BURST: strconv.Atoi: parsing "1.5": invalid syntax`},
+		{map[string]string{EnvMetricsPort: "65536"}, "invalid GLOO_PLUGIN_METRICS_PORT: 65536 is not a valid port"},
+		{map[string]string{EnvLogLevel: "verbose"}, `invalid GLOO_PLUGIN_LOG_LEVEL: not a valid logrus Level: "verbose"`},
+		{map[string]string{EnvLogFormat: "logfmt"}, `invalid GLOO_PLUGIN_LOG_FORMAT: must be text or json; got "logfmt"`},
+		{map[string]string{EnvOTLPInsecure: "maybe"}, `invalid GLOO_PLUGIN_OTLP_INSECURE: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{map[string]string{EnvDriftAction: "ignore"}, `invalid GLOO_PLUGIN_DRIFT_ACTION: must be one of reapply, verify or fail; got "ignore"`},
+		{map[string]string{EnvDryRun: "y"}, `invalid GLOO_PLUGIN_DRY_RUN: strconv.ParseBool: parsing "y": invalid syntax`},
+		{map[string]string{EnvPatchConcurrency: "0"}, "invalid GLOO_PLUGIN_PATCH_CONCURRENCY: must be at least 1; got 0"},
+		{map[string]string{EnvConfigDefaults: "a/b/c"}, `GLOO_PLUGIN_CONFIG_DEFAULTS must be in the form namespace/name; got "a/b/c"`},
+		{map[string]string{EnvClusterName: "us/east"}, `invalid GLOO_PLUGIN_CLUSTER_NAME: must not contain '/'; got "us/east"`},
+		{map[string]string{EnvKubeConfig: "/etc/gloo/kubeconfig", EnvKubeConfigSecret: "argo-rollouts/gloo-mgmt"}, "only one of GLOO_PLUGIN_KUBECONFIG and GLOO_PLUGIN_KUBECONFIG_SECRET may be set"},
+	} {
+		setEnv(t, tc.env)
+		s, err := FromEnv()
+		assert.Nil(t, s)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), tc.err)
+		}
+	}
+}
@@ -2,25 +2,71 @@ package gloo
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/util"
 
+	adminv2 "github.com/solo-io/solo-apis/client-go/admin.gloo.solo.io/v2"
 	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	resiliencev2 "github.com/solo-io/solo-apis/client-go/resilience.policy.gloo.solo.io/v2"
+	trafficcontrolv2 "github.com/solo-io/solo-apis/client-go/trafficcontrol.policy.gloo.solo.io/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type networkV2Client struct {
-	routeTableClient *routeTableClient
+	routeTableClient         RouteTableClient
+	virtualDestinationClient ObjectClient[*networkv2.VirtualDestination]
+	externalServiceClient    ObjectClient[*networkv2.ExternalService]
+	virtualGatewayClient     ObjectClient[*networkv2.VirtualGateway]
+	workspaceClient          ObjectClient[*adminv2.Workspace]
+	workspaceSettingsClient  ObjectClient[*adminv2.WorkspaceSettings]
+	trafficControlClient     TrafficControlV2ClientSet
+	resilienceClient         ResilienceV2ClientSet
+	// stops background informers, if any
+	stop context.CancelFunc
 }
 
 type NetworkV2ClientSet interface {
 	RouteTables() RouteTableClient
+	VirtualDestinations() ObjectClient[*networkv2.VirtualDestination]
+	ExternalServices() ObjectClient[*networkv2.ExternalService]
+	VirtualGateways() ObjectClient[*networkv2.VirtualGateway]
+	Workspaces() ObjectClient[*adminv2.Workspace]
+	WorkspaceSettings() ObjectClient[*adminv2.WorkspaceSettings]
+	TrafficControlPolicies() TrafficControlV2ClientSet
+	ResiliencePolicies() ResilienceV2ClientSet
+}
+
+// TrafficControlV2ClientSet covers the trafficcontrol.policy.gloo.solo.io/v2 policies
+type TrafficControlV2ClientSet interface {
+	MirrorPolicies() ObjectClient[*trafficcontrolv2.MirrorPolicy]
+	RateLimitPolicies() ObjectClient[*trafficcontrolv2.RateLimitPolicy]
+	HeaderManipulationPolicies() ObjectClient[*trafficcontrolv2.HeaderManipulationPolicy]
+	TransformationPolicies() ObjectClient[*trafficcontrolv2.TransformationPolicy]
+	LoadBalancerPolicies() ObjectClient[*trafficcontrolv2.LoadBalancerPolicy]
+	ProxyProtocolPolicies() ObjectClient[*trafficcontrolv2.ProxyProtocolPolicy]
+	HTTPBufferPolicies() ObjectClient[*trafficcontrolv2.HTTPBufferPolicy]
+}
+
+// ResilienceV2ClientSet covers the resilience.policy.gloo.solo.io/v2 policies
+type ResilienceV2ClientSet interface {
+	FailoverPolicies() ObjectClient[*resiliencev2.FailoverPolicy]
+	OutlierDetectionPolicies() ObjectClient[*resiliencev2.OutlierDetectionPolicy]
+	FaultInjectionPolicies() ObjectClient[*resiliencev2.FaultInjectionPolicy]
+	RetryTimeoutPolicies() ObjectClient[*resiliencev2.RetryTimeoutPolicy]
+	ConnectionPolicies() ObjectClient[*resiliencev2.ConnectionPolicy]
+	ActiveHealthCheckPolicies() ObjectClient[*resiliencev2.ActiveHealthCheckPolicy]
 }
 
 type RouteTableClient interface {
 	RouteTableReader
 	RouteTableWriter
+	RouteTableEventRecorder
 }
 
 type RouteTableReader interface {
@@ -34,32 +80,234 @@ type RouteTableReader interface {
 type RouteTableWriter interface {
 	// Patch patches the given RouteTable object.
 	PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error
+
+	// Apply server-side applies the given RouteTable object as FieldManager. obj should only contain the
+	// fields owned by the plugin.
+	ApplyRouteTable(ctx context.Context, obj *networkv2.RouteTable, opts ...k8sclient.PatchOption) error
+}
+
+type RouteTableEventRecorder interface {
+	// RecordRouteTableEvent creates a Kubernetes Event for the given RouteTable in the RouteTable's cluster
+	RecordRouteTableEvent(ctx context.Context, obj *networkv2.RouteTable, eventType, reason, message string) error
 }
 
 type routeTableClient struct {
 	client k8sclient.Client
 }
 
-func NewNetworkV2ClientSet() (NetworkV2ClientSet, error) {
+type clientConfig struct {
+	cacheEnabled       bool
+	cacheNamespaces    []string
+	cacheLabelSelector labels.Selector
+	qps                float32
+	burst              int
+	timeout            time.Duration
+}
+
+type ClientOption func(c *clientConfig)
+
+// WithRateLimit sets the client-side rate limit of the client set; zero values keep the client-go defaults
+func WithRateLimit(qps float32, burst int) ClientOption {
+	return func(c *clientConfig) {
+		c.qps = qps
+		c.burst = burst
+	}
+}
+
+// WithTimeout bounds every request made by the client set
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithRouteTableCache serves RouteTable reads from a shared informer scoped to the given namespaces (all
+// namespaces if empty) and label selector (all RouteTables if nil). Reads outside of that scope use the live client.
+func WithRouteTableCache(namespaces []string, selector labels.Selector) ClientOption {
+	return func(c *clientConfig) {
+		c.cacheEnabled = true
+		c.cacheNamespaces = namespaces
+		c.cacheLabelSelector = selector
+	}
+}
+
+// NewNetworkV2ClientSet creates a client set for the cluster in the default kubeconfig (or in-cluster config)
+func NewNetworkV2ClientSet(opts ...ClientOption) (NetworkV2ClientSet, error) {
 	cfg, err := util.GetKubeConfig()
 	if err != nil {
 		return nil, err
 	}
+	return NewNetworkV2ClientSetForConfig(cfg, opts...)
+}
 
-	scheme := runtime.NewScheme()
-	networkv2.AddToScheme(scheme)
-	c, err := k8sclient.New(cfg, k8sclient.Options{
+// NewNetworkV2ClientSetForConfig creates a client set for the cluster described by cfg
+func NewNetworkV2ClientSetForConfig(cfg *rest.Config, opts ...ClientOption) (NetworkV2ClientSet, error) {
+	cc := &clientConfig{}
+	for _, opt := range opts {
+		opt(cc)
+	}
+
+	cfg = rest.CopyConfig(cfg)
+	if cc.qps > 0 {
+		cfg.QPS = cc.qps
+	}
+	if cc.burst > 0 {
+		cfg.Burst = cc.burst
+	}
+	// the timeout only applies to the live client; it would cut the informer's watches short
+	liveCfg := rest.CopyConfig(cfg)
+	if cc.timeout > 0 {
+		liveCfg.Timeout = cc.timeout
+	}
+
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
+	c, err := k8sclient.New(liveCfg, k8sclient.Options{
 		Scheme: scheme,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	cs := NewNetworkV2ClientSetForClient(c).(networkV2Client)
+	if cc.cacheEnabled {
+		cached, stop, err := newCachedRouteTableClient(cfg, scheme, cs.routeTableClient.(*routeTableClient), cc)
+		if err != nil {
+			return nil, err
+		}
+		cs.routeTableClient = cached
+		cs.stop = stop
+	}
+	return cs, nil
+}
+
+// NewScheme returns a scheme with every Gloo Platform API used by the plugin registered
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		// Events
+		corev1.AddToScheme,
+		networkv2.AddToScheme,
+		adminv2.AddToScheme,
+		trafficcontrolv2.AddToScheme,
+		resiliencev2.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, err
+		}
+	}
+	return scheme, nil
+}
+
+// NewNetworkV2ClientSetForClient creates a client set backed by c; c's scheme must include the types
+// registered by NewScheme
+func NewNetworkV2ClientSetForClient(c k8sclient.Client) NetworkV2ClientSet {
 	return networkV2Client{
 		routeTableClient: &routeTableClient{client: c},
-	}, nil
+		virtualDestinationClient: newObjectClient(c,
+			func() *networkv2.VirtualDestination { return &networkv2.VirtualDestination{} },
+			func() *networkv2.VirtualDestinationList { return &networkv2.VirtualDestinationList{} }),
+		externalServiceClient: newObjectClient(c,
+			func() *networkv2.ExternalService { return &networkv2.ExternalService{} },
+			func() *networkv2.ExternalServiceList { return &networkv2.ExternalServiceList{} }),
+		virtualGatewayClient: newObjectClient(c,
+			func() *networkv2.VirtualGateway { return &networkv2.VirtualGateway{} },
+			func() *networkv2.VirtualGatewayList { return &networkv2.VirtualGatewayList{} }),
+		workspaceClient: newObjectClient(c,
+			func() *adminv2.Workspace { return &adminv2.Workspace{} },
+			func() *adminv2.WorkspaceList { return &adminv2.WorkspaceList{} }),
+		workspaceSettingsClient: newObjectClient(c,
+			func() *adminv2.WorkspaceSettings { return &adminv2.WorkspaceSettings{} },
+			func() *adminv2.WorkspaceSettingsList { return &adminv2.WorkspaceSettingsList{} }),
+		trafficControlClient: trafficControlV2Client{client: c},
+		resilienceClient:     resilienceV2Client{client: c},
+	}
 }
 
 func (c networkV2Client) RouteTables() RouteTableClient {
-	return c.routeTableClient
+	return tracedRouteTableClient{c.routeTableClient}
+}
+
+func (c networkV2Client) VirtualDestinations() ObjectClient[*networkv2.VirtualDestination] {
+	return c.virtualDestinationClient
+}
+
+func (c networkV2Client) ExternalServices() ObjectClient[*networkv2.ExternalService] {
+	return c.externalServiceClient
+}
+
+func (c networkV2Client) VirtualGateways() ObjectClient[*networkv2.VirtualGateway] {
+	return c.virtualGatewayClient
+}
+
+func (c networkV2Client) Workspaces() ObjectClient[*adminv2.Workspace] {
+	return c.workspaceClient
+}
+
+func (c networkV2Client) WorkspaceSettings() ObjectClient[*adminv2.WorkspaceSettings] {
+	return c.workspaceSettingsClient
+}
+
+func (c networkV2Client) TrafficControlPolicies() TrafficControlV2ClientSet {
+	return c.trafficControlClient
+}
+
+func (c networkV2Client) ResiliencePolicies() ResilienceV2ClientSet {
+	return c.resilienceClient
+}
+
+// ClientSetCache caches client sets per target cluster so that clients (and their connections) are
+// reused across plugin calls
+type ClientSetCache struct {
+	mu      sync.Mutex
+	entries map[string]*clientSetCacheEntry
+	opts    []ClientOption
+}
+
+type clientSetCacheEntry struct {
+	version   string
+	clientSet NetworkV2ClientSet
+}
+
+// NewClientSetCache creates a cache whose client sets are created with the given options
+func NewClientSetCache(opts ...ClientOption) *ClientSetCache {
+	return &ClientSetCache{
+		entries: map[string]*clientSetCacheEntry{},
+		opts:    opts,
+	}
+}
+
+// GetOrCreate returns the cached client set for the target cluster key, or creates one from the rest config
+// returned by getConfig. getConfig is only called on a cache miss or when version (e.g. the resourceVersion
+// of the Secret holding the kubeconfig) differs from the cached client set's version.
+func (c *ClientSetCache) GetOrCreate(key, version string, getConfig func() (*rest.Config, error)) (NetworkV2ClientSet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if ok && e.version == version {
+		return e.clientSet, nil
+	}
+
+	cfg, err := getConfig()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := NewNetworkV2ClientSetForConfig(cfg, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// the previous client set is no longer reachable once replaced
+		if old, isNetworkV2 := e.clientSet.(networkV2Client); isNetworkV2 && old.stop != nil {
+			old.stop()
+		}
+	}
+	c.entries[key] = &clientSetCacheEntry{
+		version:   version,
+		clientSet: cs,
+	}
+	return cs, nil
 }
@@ -0,0 +1,72 @@
+package gloo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// newTestAPIServer serves discovery for RouteTables and handles RouteTable requests with handler
+func newTestAPIServer(t *testing.T, handler http.HandlerFunc) *rest.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/api":
+			fmt.Fprint(w, `{"kind":"APIVersions","versions":[]}`)
+		case "/apis":
+			fmt.Fprint(w, `{"kind":"APIGroupList","apiVersion":"v1","groups":[{"name":"networking.gloo.solo.io","versions":[{"groupVersion":"networking.gloo.solo.io/v2","version":"v2"}],"preferredVersion":{"groupVersion":"networking.gloo.solo.io/v2","version":"v2"}}]}`)
+		case "/apis/networking.gloo.solo.io/v2":
+			fmt.Fprint(w, `{"kind":"APIResourceList","apiVersion":"v1","groupVersion":"networking.gloo.solo.io/v2","resources":[{"name":"routetables","singularName":"routetable","namespaced":true,"kind":"RouteTable","verbs":["get","list","patch"]}]}`)
+		default:
+			handler(w, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &rest.Config{Host: server.URL}
+}
+
+func notFound(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+}
+
+func TestClientTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	cfg := newTestAPIServer(t, func(w http.ResponseWriter, req *http.Request) {
+		// an unresponsive API server
+		select {
+		case <-unblock:
+		case <-req.Context().Done():
+		}
+	})
+
+	cs, err := NewNetworkV2ClientSetForConfig(cfg, WithTimeout(100*time.Millisecond))
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = cs.RouteTables().GetRouteTable(context.Background(), "demo", "gloo-mesh")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestClientRateLimit(t *testing.T) {
+	cfg := newTestAPIServer(t, notFound)
+
+	// the first request uses the burst, each of the others waits 100ms
+	cs, err := NewNetworkV2ClientSetForConfig(cfg, WithRateLimit(10, 1))
+	assert.NoError(t, err)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err = cs.RouteTables().GetRouteTable(context.Background(), "demo", "gloo-mesh")
+		assert.True(t, apierrors.IsNotFound(err), err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
@@ -0,0 +1,226 @@
+package gloo
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// identityKeys identify an element of a list of objects, e.g. a route by its name or a destination by its ref
+var identityKeys = []string{"name", "ref"}
+
+// createJSONPatch creates the smallest JSON patch that turns orig into new. Lists are compared element by
+// element; every changed element is preceded by a test operation on its identity (or on the whole element) and
+// every replaced or removed value by a test operation on its current value, so the patch fails instead of
+// changing the wrong element if the object was modified (e.g. its routes or destinations reordered) after orig
+// was read.
+//
+// Added values have no current value to test: a field missing from orig may have been set since (zero values,
+// such as a weight of 0, are omitted from JSON) and a list may have grown. A patch with add operations therefore
+// tests resourceVersion, the version of the object orig was read from, unless it is empty.
+func createJSONPatch(orig, new interface{}, resourceVersion string) ([]byte, bool, error) {
+	origJSON, err := toJSONValue(orig)
+	if err != nil {
+		return nil, false, err
+	}
+	newJSON, err := toJSONValue(new)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ops := []jsonPatchOperation{}
+	if err := diffJSON("", origJSON, newJSON, &ops); err != nil {
+		return nil, false, err
+	}
+	if resourceVersion != "" && hasOp(ops, "add") {
+		lock := []jsonPatchOperation{}
+		if err := appendOp(&lock, "test", "/metadata/resourceVersion", resourceVersion); err != nil {
+			return nil, false, err
+		}
+		ops = append(lock, ops...)
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return nil, false, err
+	}
+	return patch, len(ops) > 0, nil
+}
+
+func toJSONValue(obj interface{}) (interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func diffJSON(path string, orig, new interface{}, ops *[]jsonPatchOperation) error {
+	if reflect.DeepEqual(orig, new) {
+		return nil
+	}
+
+	switch o := orig.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			return diffJSONObject(path, o, n, ops)
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			return diffJSONList(path, o, n, ops)
+		}
+	}
+
+	if err := appendOp(ops, "test", path, orig); err != nil {
+		return err
+	}
+	return appendOp(ops, "replace", path, new)
+}
+
+func diffJSONObject(path string, orig, new map[string]interface{}, ops *[]jsonPatchOperation) error {
+	for _, k := range sortedKeys(new) {
+		childPath := path + "/" + escapeJSONPointer(k)
+		o, ok := orig[k]
+		if !ok {
+			if err := appendOp(ops, "add", childPath, new[k]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := diffJSON(childPath, o, new[k], ops); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(orig) {
+		if _, ok := new[k]; ok {
+			continue
+		}
+		childPath := path + "/" + escapeJSONPointer(k)
+		if err := appendOp(ops, "test", childPath, orig[k]); err != nil {
+			return err
+		}
+		if err := appendOp(ops, "remove", childPath, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffJSONList(path string, orig, new []interface{}, ops *[]jsonPatchOperation) error {
+	common := len(orig)
+	if len(new) < common {
+		common = len(new)
+	}
+
+	for i := 0; i < common; i++ {
+		if reflect.DeepEqual(orig[i], new[i]) {
+			continue
+		}
+		elemPath := path + "/" + strconv.Itoa(i)
+		if key, ok := sharedIdentity(orig[i], new[i]); ok {
+			// same element; test its identity and patch the fields that changed
+			if err := appendOp(ops, "test", elemPath+"/"+key, orig[i].(map[string]interface{})[key]); err != nil {
+				return err
+			}
+			if err := diffJSON(elemPath, orig[i], new[i], ops); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := appendOp(ops, "test", elemPath, orig[i]); err != nil {
+			return err
+		}
+		if err := appendOp(ops, "replace", elemPath, new[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := common; i < len(new); i++ {
+		if err := appendOp(ops, "add", path+"/-", new[i]); err != nil {
+			return err
+		}
+	}
+	// remove from the end so that earlier indexes stay valid
+	for i := len(orig) - 1; i >= common; i-- {
+		elemPath := path + "/" + strconv.Itoa(i)
+		if err := appendOp(ops, "test", elemPath, orig[i]); err != nil {
+			return err
+		}
+		if err := appendOp(ops, "remove", elemPath, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedIdentity returns the identity key that orig and new both have with the same value
+func sharedIdentity(orig, new interface{}) (string, bool) {
+	o, ok := orig.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	n, ok := new.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	for _, key := range identityKeys {
+		ov, ok := o[key]
+		if !ok {
+			continue
+		}
+		if nv, ok := n[key]; ok && reflect.DeepEqual(ov, nv) {
+			return key, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+func appendOp(ops *[]jsonPatchOperation, op, path string, value interface{}) error {
+	operation := jsonPatchOperation{
+		Op:   op,
+		Path: path,
+	}
+	if op != "remove" {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		operation.Value = b
+	}
+	*ops = append(*ops, operation)
+	return nil
+}
+
+func hasOp(ops []jsonPatchOperation, op string) bool {
+	for _, operation := range ops {
+		if operation.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
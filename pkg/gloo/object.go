@@ -0,0 +1,75 @@
+package gloo
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectClient reads and writes Gloo Platform resources of type T
+type ObjectClient[T k8sclient.Object] interface {
+	ObjectReader[T]
+	ObjectWriter[T]
+}
+
+type ObjectReader[T k8sclient.Object] interface {
+	// Get retrieves the object for the given name and namespace
+	Get(ctx context.Context, name string, namespace string) (T, error)
+
+	// List retrieves the objects matching the given list options
+	List(ctx context.Context, opts ...k8sclient.ListOption) ([]T, error)
+}
+
+type ObjectWriter[T k8sclient.Object] interface {
+	// Patch patches the given object
+	Patch(ctx context.Context, obj T, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error
+}
+
+type objectClient[T k8sclient.Object, L k8sclient.ObjectList] struct {
+	client  k8sclient.Client
+	newObj  func() T
+	newList func() L
+}
+
+func newObjectClient[T k8sclient.Object, L k8sclient.ObjectList](c k8sclient.Client, newObj func() T, newList func() L) *objectClient[T, L] {
+	return &objectClient[T, L]{
+		client:  c,
+		newObj:  newObj,
+		newList: newList,
+	}
+}
+
+func (c *objectClient[T, L]) Get(ctx context.Context, name string, namespace string) (T, error) {
+	obj := c.newObj()
+	if err := c.client.Get(ctx, k8sclient.ObjectKey{Name: name, Namespace: namespace}, obj); err != nil {
+		var empty T
+		return empty, err
+	}
+	return obj, nil
+}
+
+func (c *objectClient[T, L]) List(ctx context.Context, opts ...k8sclient.ListOption) ([]T, error) {
+	list := c.newList()
+	if err := c.client.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var result []T
+	for _, item := range items {
+		obj, ok := item.(T)
+		if !ok {
+			return nil, fmt.Errorf("unexpected list item type %T", item)
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+func (c *objectClient[T, L]) Patch(ctx context.Context, obj T, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	return c.client.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,70 @@
+package gloo
+
+import (
+	"context"
+	"testing"
+
+	adminv2 "github.com/solo-io/solo-apis/client-go/admin.gloo.solo.io/v2"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestClientSet(t *testing.T, objs ...k8sclient.Object) NetworkV2ClientSet {
+	t.Helper()
+	scheme, err := NewScheme()
+	assert.NoError(t, err)
+	return NewNetworkV2ClientSetForClient(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build())
+}
+
+func TestObjectClient(t *testing.T) {
+	ctx := context.Background()
+	cs := newTestClientSet(t,
+		&networkv2.VirtualDestination{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "gloo-mesh", Labels: map[string]string{"app": "demo"}}},
+		&networkv2.VirtualDestination{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "gloo-mesh"}},
+		&networkv2.VirtualDestination{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "web", Labels: map[string]string{"app": "demo"}}},
+		&adminv2.Workspace{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "gloo-mesh"}},
+	)
+	vds := cs.VirtualDestinations()
+
+	vd, err := vds.Get(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", vd.Name)
+	assert.Equal(t, "gloo-mesh", vd.Namespace)
+	_, err = vds.Get(ctx, "missing", "gloo-mesh")
+	assert.True(t, apierrors.IsNotFound(err), err)
+
+	list, err := vds.List(ctx, k8sclient.InNamespace("gloo-mesh"))
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	list, err = vds.List(ctx, k8sclient.MatchingLabels{"app": "demo"})
+	assert.NoError(t, err)
+	var names []string
+	for _, vd := range list {
+		names = append(names, vd.Namespace+"/"+vd.Name)
+	}
+	assert.ElementsMatch(t, []string{"gloo-mesh/demo", "web/demo"}, names)
+	list, err = vds.List(ctx, k8sclient.InNamespace("other"))
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	patch := k8sclient.MergeFrom(vd.DeepCopy())
+	vd.Labels["team"] = "a"
+	assert.NoError(t, vds.Patch(ctx, vd, patch))
+	vd, err = vds.Get(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "demo", "team": "a"}, vd.Labels)
+
+	// every client reads its own type
+	ws, err := cs.Workspaces().List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, ws, 1)
+	_, err = cs.ExternalServices().Get(ctx, "demo", "gloo-mesh")
+	assert.True(t, apierrors.IsNotFound(err), err)
+	policies, err := cs.ResiliencePolicies().FailoverPolicies().List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+}
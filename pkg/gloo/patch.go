@@ -11,6 +11,7 @@ type patchConfig struct {
 	withAnnotations bool
 	withLabels      bool
 	withSpec        bool
+	jsonPatch       bool
 }
 
 type PatchOption func(p *patchConfig)
@@ -33,6 +34,15 @@ func WithSpec() PatchOption {
 	}
 }
 
+// AsJSONPatch builds a JSON patch (RFC 6902) instead of a merge patch. See createJSONPatch.
+func AsJSONPatch() PatchOption {
+	return func(p *patchConfig) {
+		p.jsonPatch = true
+	}
+}
+
+// BuildRouteTablePatch builds a patch from current to desired covering the parts of the RouteTable selected by
+// opts. The returned bool is false if the patch is empty.
 func BuildRouteTablePatch(current, desired *networkv2.RouteTable, opts ...PatchOption) ([]byte, bool, error) {
 	cfg := &patchConfig{}
 	for _, opt := range opts {
@@ -54,6 +64,9 @@ func BuildRouteTablePatch(current, desired *networkv2.RouteTable, opts ...PatchO
 		desired.Spec.DeepCopyInto(&des.Spec)
 	}
 
+	if cfg.jsonPatch {
+		return createJSONPatch(cur, des, current.ResourceVersion)
+	}
 	return createTwoWayMergePatch(cur, des, networkv2.RouteTable{})
 }
 
@@ -0,0 +1,155 @@
+package gloo
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	solov2 "github.com/solo-io/solo-apis/client-go/common.gloo.solo.io/v2"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouteTable() *networkv2.RouteTable {
+	rt := &networkv2.RouteTable{}
+	rt.Name = "demo"
+	rt.Namespace = "gloo-mesh"
+	rt.Spec.Http = []*networkv2.HTTPRoute{
+		{
+			Name: "demo",
+			ActionType: &networkv2.HTTPRoute_ForwardTo{
+				ForwardTo: &networkv2.ForwardToAction{
+					Destinations: []*solov2.DestinationReference{
+						newTestDest("stable", 100),
+					},
+				},
+			},
+		},
+	}
+	return rt
+}
+
+func newTestDest(name string, weight uint32) *solov2.DestinationReference {
+	return &solov2.DestinationReference{
+		RefKind: &solov2.DestinationReference_Ref{
+			Ref: &solov2.ObjectReference{Name: name, Namespace: "demo"},
+		},
+		Weight: weight,
+	}
+}
+
+func applyJSONPatch(t *testing.T, rt *networkv2.RouteTable, patch []byte) *networkv2.RouteTable {
+	doc, err := json.Marshal(rt)
+	assert.NoError(t, err)
+	p, err := jsonpatch.DecodePatch(patch)
+	assert.NoError(t, err)
+	patched, err := p.Apply(doc)
+	assert.NoError(t, err)
+	result := &networkv2.RouteTable{}
+	assert.NoError(t, json.Unmarshal(patched, result))
+	return result
+}
+
+func TestBuildRouteTableJSONPatchNoChange(t *testing.T) {
+	rt := newTestRouteTable()
+	patch, changed, err := BuildRouteTablePatch(rt, rt.DeepCopy(), WithSpec(), WithAnnotations(), AsJSONPatch())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.JSONEq(t, "[]", string(patch))
+}
+
+func TestBuildRouteTableJSONPatchWeights(t *testing.T) {
+	current := newTestRouteTable()
+	current.ResourceVersion = "7"
+	desired := current.DeepCopy()
+	dests := desired.Spec.Http[0].GetForwardTo().Destinations
+	dests[0].Weight = 90
+	desired.Spec.Http[0].GetForwardTo().Destinations = append(dests, newTestDest("canary", 10))
+
+	patch, changed, err := BuildRouteTablePatch(current, desired, WithSpec(), AsJSONPatch())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.JSONEq(t, `[
+		{"op":"test","path":"/metadata/resourceVersion","value":"7"},
+		{"op":"test","path":"/spec/http/0/name","value":"demo"},
+		{"op":"test","path":"/spec/http/0/forwardTo/destinations/0/ref","value":{"name":"stable","namespace":"demo"}},
+		{"op":"test","path":"/spec/http/0/forwardTo/destinations/0/weight","value":100},
+		{"op":"replace","path":"/spec/http/0/forwardTo/destinations/0/weight","value":90},
+		{"op":"add","path":"/spec/http/0/forwardTo/destinations/-","value":{"ref":{"name":"canary","namespace":"demo"},"weight":10}}
+	]`, string(patch))
+
+	patched := applyJSONPatch(t, current, patch)
+	assert.True(t, patched.Spec.Equal(&desired.Spec))
+}
+
+func TestBuildRouteTableJSONPatchDetectsReorder(t *testing.T) {
+	current := newTestRouteTable()
+	desired := current.DeepCopy()
+	desired.Spec.Http[0].GetForwardTo().Destinations[0].Weight = 90
+
+	patch, _, err := BuildRouteTablePatch(current, desired, WithSpec(), AsJSONPatch())
+	assert.NoError(t, err)
+
+	// another destination was inserted in front of stable after current was read
+	live := current.DeepCopy()
+	live.Spec.Http[0].GetForwardTo().Destinations = append([]*solov2.DestinationReference{newTestDest("legacy", 0)}, live.Spec.Http[0].GetForwardTo().Destinations...)
+
+	doc, err := json.Marshal(live)
+	assert.NoError(t, err)
+	p, err := jsonpatch.DecodePatch(patch)
+	assert.NoError(t, err)
+	_, err = p.Apply(doc)
+	assert.Error(t, err)
+}
+
+func TestBuildRouteTableJSONPatchDetectsZeroValueChange(t *testing.T) {
+	current := newTestRouteTable()
+	current.ResourceVersion = "7"
+	current.Spec.Http[0].GetForwardTo().Destinations[0].Weight = 0
+	desired := current.DeepCopy()
+	desired.Spec.Http[0].GetForwardTo().Destinations[0].Weight = 90
+
+	// a weight of 0 is omitted, so the weight is added rather than replaced and the patch tests the resourceVersion
+	patch, _, err := BuildRouteTablePatch(current, desired, WithSpec(), AsJSONPatch())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"test","path":"/metadata/resourceVersion","value":"7"},
+		{"op":"test","path":"/spec/http/0/name","value":"demo"},
+		{"op":"test","path":"/spec/http/0/forwardTo/destinations/0/ref","value":{"name":"stable","namespace":"demo"}},
+		{"op":"add","path":"/spec/http/0/forwardTo/destinations/0/weight","value":90}
+	]`, string(patch))
+	assert.Equal(t, uint32(90), applyJSONPatch(t, current, patch).Spec.Http[0].GetForwardTo().Destinations[0].Weight)
+
+	// the weight was changed from 0 to 77 after current was read
+	live := current.DeepCopy()
+	live.ResourceVersion = "8"
+	live.Spec.Http[0].GetForwardTo().Destinations[0].Weight = 77
+
+	doc, err := json.Marshal(live)
+	assert.NoError(t, err)
+	p, err := jsonpatch.DecodePatch(patch)
+	assert.NoError(t, err)
+	_, err = p.Apply(doc)
+	assert.ErrorContains(t, err, "testing value /metadata/resourceVersion failed")
+}
+
+func TestBuildRouteTableJSONPatchAnnotations(t *testing.T) {
+	current := newTestRouteTable()
+	desired := current.DeepCopy()
+	desired.Annotations = map[string]string{"example.com/key": "value"}
+
+	patch, changed, err := BuildRouteTablePatch(current, desired, WithAnnotations(), AsJSONPatch())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.JSONEq(t, `[{"op":"add","path":"/metadata/annotations","value":{"example.com/key":"value"}}]`, string(patch))
+
+	current = desired.DeepCopy()
+	desired.Annotations["example.com/key"] = "other"
+	patch, _, err = BuildRouteTablePatch(current, desired, WithAnnotations(), AsJSONPatch())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"test","path":"/metadata/annotations/example.com~1key","value":"value"},
+		{"op":"replace","path":"/metadata/annotations/example.com~1key","value":"other"}
+	]`, string(patch))
+	assert.Equal(t, "other", applyJSONPatch(t, current, patch).Annotations["example.com/key"])
+}
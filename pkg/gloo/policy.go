@@ -0,0 +1,95 @@
+package gloo
+
+import (
+	resiliencev2 "github.com/solo-io/solo-apis/client-go/resilience.policy.gloo.solo.io/v2"
+	trafficcontrolv2 "github.com/solo-io/solo-apis/client-go/trafficcontrol.policy.gloo.solo.io/v2"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type trafficControlV2Client struct {
+	client k8sclient.Client
+}
+
+func (c trafficControlV2Client) MirrorPolicies() ObjectClient[*trafficcontrolv2.MirrorPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.MirrorPolicy { return &trafficcontrolv2.MirrorPolicy{} },
+		func() *trafficcontrolv2.MirrorPolicyList { return &trafficcontrolv2.MirrorPolicyList{} })
+}
+
+func (c trafficControlV2Client) RateLimitPolicies() ObjectClient[*trafficcontrolv2.RateLimitPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.RateLimitPolicy { return &trafficcontrolv2.RateLimitPolicy{} },
+		func() *trafficcontrolv2.RateLimitPolicyList { return &trafficcontrolv2.RateLimitPolicyList{} })
+}
+
+func (c trafficControlV2Client) HeaderManipulationPolicies() ObjectClient[*trafficcontrolv2.HeaderManipulationPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.HeaderManipulationPolicy { return &trafficcontrolv2.HeaderManipulationPolicy{} },
+		func() *trafficcontrolv2.HeaderManipulationPolicyList {
+			return &trafficcontrolv2.HeaderManipulationPolicyList{}
+		})
+}
+
+func (c trafficControlV2Client) TransformationPolicies() ObjectClient[*trafficcontrolv2.TransformationPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.TransformationPolicy { return &trafficcontrolv2.TransformationPolicy{} },
+		func() *trafficcontrolv2.TransformationPolicyList { return &trafficcontrolv2.TransformationPolicyList{} })
+}
+
+func (c trafficControlV2Client) LoadBalancerPolicies() ObjectClient[*trafficcontrolv2.LoadBalancerPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.LoadBalancerPolicy { return &trafficcontrolv2.LoadBalancerPolicy{} },
+		func() *trafficcontrolv2.LoadBalancerPolicyList { return &trafficcontrolv2.LoadBalancerPolicyList{} })
+}
+
+func (c trafficControlV2Client) ProxyProtocolPolicies() ObjectClient[*trafficcontrolv2.ProxyProtocolPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.ProxyProtocolPolicy { return &trafficcontrolv2.ProxyProtocolPolicy{} },
+		func() *trafficcontrolv2.ProxyProtocolPolicyList { return &trafficcontrolv2.ProxyProtocolPolicyList{} })
+}
+
+func (c trafficControlV2Client) HTTPBufferPolicies() ObjectClient[*trafficcontrolv2.HTTPBufferPolicy] {
+	return newObjectClient(c.client,
+		func() *trafficcontrolv2.HTTPBufferPolicy { return &trafficcontrolv2.HTTPBufferPolicy{} },
+		func() *trafficcontrolv2.HTTPBufferPolicyList { return &trafficcontrolv2.HTTPBufferPolicyList{} })
+}
+
+type resilienceV2Client struct {
+	client k8sclient.Client
+}
+
+func (c resilienceV2Client) FailoverPolicies() ObjectClient[*resiliencev2.FailoverPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.FailoverPolicy { return &resiliencev2.FailoverPolicy{} },
+		func() *resiliencev2.FailoverPolicyList { return &resiliencev2.FailoverPolicyList{} })
+}
+
+func (c resilienceV2Client) OutlierDetectionPolicies() ObjectClient[*resiliencev2.OutlierDetectionPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.OutlierDetectionPolicy { return &resiliencev2.OutlierDetectionPolicy{} },
+		func() *resiliencev2.OutlierDetectionPolicyList { return &resiliencev2.OutlierDetectionPolicyList{} })
+}
+
+func (c resilienceV2Client) FaultInjectionPolicies() ObjectClient[*resiliencev2.FaultInjectionPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.FaultInjectionPolicy { return &resiliencev2.FaultInjectionPolicy{} },
+		func() *resiliencev2.FaultInjectionPolicyList { return &resiliencev2.FaultInjectionPolicyList{} })
+}
+
+func (c resilienceV2Client) RetryTimeoutPolicies() ObjectClient[*resiliencev2.RetryTimeoutPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.RetryTimeoutPolicy { return &resiliencev2.RetryTimeoutPolicy{} },
+		func() *resiliencev2.RetryTimeoutPolicyList { return &resiliencev2.RetryTimeoutPolicyList{} })
+}
+
+func (c resilienceV2Client) ConnectionPolicies() ObjectClient[*resiliencev2.ConnectionPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.ConnectionPolicy { return &resiliencev2.ConnectionPolicy{} },
+		func() *resiliencev2.ConnectionPolicyList { return &resiliencev2.ConnectionPolicyList{} })
+}
+
+func (c resilienceV2Client) ActiveHealthCheckPolicies() ObjectClient[*resiliencev2.ActiveHealthCheckPolicy] {
+	return newObjectClient(c.client,
+		func() *resiliencev2.ActiveHealthCheckPolicy { return &resiliencev2.ActiveHealthCheckPolicy{} },
+		func() *resiliencev2.ActiveHealthCheckPolicyList { return &resiliencev2.ActiveHealthCheckPolicyList{} })
+}
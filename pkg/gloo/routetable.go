@@ -4,9 +4,14 @@ import (
 	"context"
 
 	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// FieldManager is the field manager the plugin uses to server-side apply RouteTables
+const FieldManager = "glooplatform-rollouts-plugin"
+
 func (c *routeTableClient) GetRouteTable(ctx context.Context, name string, namespace string) (*networkv2.RouteTable, error) {
 	rt := &networkv2.RouteTable{}
 	if err := c.client.Get(ctx, k8sclient.ObjectKey{Name: name, Namespace: namespace}, rt); err != nil {
@@ -30,3 +35,37 @@ func (c *routeTableClient) ListRouteTable(ctx context.Context, opts ...k8sclient
 func (c *routeTableClient) PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
 	return c.client.Patch(ctx, obj, patch, opts...)
 }
+
+func (c *routeTableClient) ApplyRouteTable(ctx context.Context, obj *networkv2.RouteTable, opts ...k8sclient.PatchOption) error {
+	// apply requests must carry the type and must not carry managedFields
+	obj.SetGroupVersionKind(networkv2.RouteTableGVK)
+	obj.ManagedFields = nil
+	return c.client.Patch(ctx, obj, k8sclient.Apply, append([]k8sclient.PatchOption{k8sclient.FieldOwner(FieldManager)}, opts...)...)
+}
+
+func (c *routeTableClient) RecordRouteTableEvent(ctx context.Context, obj *networkv2.RouteTable, eventType, reason, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: obj.Name + ".",
+			Namespace:    obj.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      networkv2.RouteTableGVK.GroupVersion().String(),
+			Kind:            networkv2.RouteTableGVK.Kind,
+			Name:            obj.Name,
+			Namespace:       obj.Namespace,
+			UID:             obj.UID,
+			ResourceVersion: obj.ResourceVersion,
+		},
+		Type:                eventType,
+		Reason:              reason,
+		Message:             message,
+		Source:              corev1.EventSource{Component: FieldManager},
+		ReportingController: FieldManager,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+	return c.client.Create(ctx, event)
+}
@@ -0,0 +1,250 @@
+package gloo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cachedRouteTableClient serves RouteTable reads from a shared informer. Reads fall back to the live client
+// when they are outside of the informer's namespaces or label selector, before the informer has synced, or
+// when the informer has not yet observed the latest resourceVersion known to this client (e.g. right after
+// a patch). Writes always go to the live client.
+type cachedRouteTableClient struct {
+	live          *routeTableClient
+	informerCache cache.Cache
+	namespaces    map[string]bool
+	selector      labels.Selector
+	synced        atomic.Bool
+
+	mu sync.Mutex
+	// latest resourceVersion known to this client for RouteTables whose update the informer may not have seen yet
+	resourceVersions map[types.NamespacedName]string
+}
+
+func newCachedRouteTableClient(cfg *rest.Config, scheme *runtime.Scheme, live *routeTableClient, cc *clientConfig) (*cachedRouteTableClient, context.CancelFunc, error) {
+	opts := cache.Options{
+		Scheme: scheme,
+	}
+	if cc.cacheLabelSelector != nil && !cc.cacheLabelSelector.Empty() {
+		opts.SelectorsByObject = cache.SelectorsByObject{
+			&networkv2.RouteTable{}: {Label: cc.cacheLabelSelector},
+		}
+	}
+
+	newCache := cache.New
+	switch len(cc.cacheNamespaces) {
+	case 0:
+	case 1:
+		opts.Namespace = cc.cacheNamespaces[0]
+	default:
+		newCache = cache.MultiNamespacedCacheBuilder(cc.cacheNamespaces)
+	}
+
+	informerCache, err := newCache(cfg, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &cachedRouteTableClient{
+		live:             live,
+		informerCache:    informerCache,
+		namespaces:       map[string]bool{},
+		selector:         cc.cacheLabelSelector,
+		resourceVersions: map[types.NamespacedName]string{},
+	}
+	for _, ns := range cc.cacheNamespaces {
+		c.namespaces[ns] = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// reads are served by the live client until the informer has synced; if the informer never syncs
+	// (e.g. missing list/watch RBAC) the live client keeps serving all reads
+	go informerCache.Start(ctx) //nolint:errcheck
+	go func() {
+		if _, err := informerCache.GetInformer(ctx, &networkv2.RouteTable{}); err != nil {
+			return
+		}
+		if informerCache.WaitForCacheSync(ctx) {
+			c.synced.Store(true)
+		}
+	}()
+
+	return c, cancel, nil
+}
+
+func (c *cachedRouteTableClient) GetRouteTable(ctx context.Context, name string, namespace string) (*networkv2.RouteTable, error) {
+	if !c.synced.Load() || !c.inNamespaceScope(namespace) {
+		return c.live.GetRouteTable(ctx, name, namespace)
+	}
+
+	rt := &networkv2.RouteTable{}
+	if err := c.informerCache.Get(ctx, k8sclient.ObjectKey{Name: name, Namespace: namespace}, rt); err != nil {
+		// not found in the cache may mean the RouteTable is outside of the label selector or was just created
+		return c.getLive(ctx, name, namespace)
+	}
+	if !c.isCurrent(rt) {
+		return c.getLive(ctx, name, namespace)
+	}
+	return rt, nil
+}
+
+func (c *cachedRouteTableClient) ListRouteTable(ctx context.Context, opts ...k8sclient.ListOption) ([]*networkv2.RouteTable, error) {
+	listOpts := &k8sclient.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	if !c.synced.Load() || !c.inListScope(listOpts) {
+		return c.live.ListRouteTable(ctx, opts...)
+	}
+
+	rtl := &networkv2.RouteTableList{}
+	if err := c.informerCache.List(ctx, rtl, opts...); err != nil {
+		return c.live.ListRouteTable(ctx, opts...)
+	}
+	var result []*networkv2.RouteTable
+	for i := 0; i < len(rtl.Items); i++ {
+		if !c.isCurrent(&rtl.Items[i]) {
+			return c.listLive(ctx, opts...)
+		}
+		result = append(result, &rtl.Items[i])
+	}
+	return result, nil
+}
+
+func (c *cachedRouteTableClient) PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	if err := c.live.PatchRouteTable(ctx, obj, patch, opts...); err != nil {
+		// the patch may have failed because the RouteTable changed since it was read (a conflict or a failed
+		// JSON patch test); the next read must not come from a stale cache
+		c.setStale(obj)
+		return err
+	}
+	c.setResourceVersion(obj)
+	return nil
+}
+
+func (c *cachedRouteTableClient) ApplyRouteTable(ctx context.Context, obj *networkv2.RouteTable, opts ...k8sclient.PatchOption) error {
+	if err := c.live.ApplyRouteTable(ctx, obj, opts...); err != nil {
+		// like a failed patch, e.g. a conflict with another field manager
+		c.setStale(obj)
+		return err
+	}
+	c.setResourceVersion(obj)
+	return nil
+}
+
+func (c *cachedRouteTableClient) RecordRouteTableEvent(ctx context.Context, obj *networkv2.RouteTable, eventType, reason, message string) error {
+	return c.live.RecordRouteTableEvent(ctx, obj, eventType, reason, message)
+}
+
+func (c *cachedRouteTableClient) getLive(ctx context.Context, name string, namespace string) (*networkv2.RouteTable, error) {
+	rt, err := c.live.GetRouteTable(ctx, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.updateResourceVersion(rt)
+	return rt, nil
+}
+
+func (c *cachedRouteTableClient) listLive(ctx context.Context, opts ...k8sclient.ListOption) ([]*networkv2.RouteTable, error) {
+	rts, err := c.live.ListRouteTable(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range rts {
+		c.updateResourceVersion(rt)
+	}
+	return rts, nil
+}
+
+func (c *cachedRouteTableClient) inNamespaceScope(namespace string) bool {
+	if len(c.namespaces) == 0 {
+		return true
+	}
+	return c.namespaces[namespace]
+}
+
+// inListScope returns true if every RouteTable matched by the list options is guaranteed to be in the cache
+func (c *cachedRouteTableClient) inListScope(opts *k8sclient.ListOptions) bool {
+	if opts.FieldSelector != nil && !opts.FieldSelector.Empty() {
+		return false
+	}
+	if opts.Namespace == "" && len(c.namespaces) > 0 {
+		return false
+	}
+	if !c.inNamespaceScope(opts.Namespace) {
+		return false
+	}
+	if c.selector == nil || c.selector.Empty() {
+		return true
+	}
+	if opts.LabelSelector == nil {
+		return false
+	}
+
+	// the requested selector must include every requirement of the cache selector
+	requested, _ := opts.LabelSelector.Requirements()
+	requestedSet := map[string]bool{}
+	for _, req := range requested {
+		requestedSet[req.String()] = true
+	}
+	required, _ := c.selector.Requirements()
+	for _, req := range required {
+		if !requestedSet[req.String()] {
+			return false
+		}
+	}
+	return true
+}
+
+// isCurrent returns false if this client knows of a resourceVersion for rt that the informer has not seen yet
+func (c *cachedRouteTableClient) isCurrent(rt *networkv2.RouteTable) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}
+	rv, ok := c.resourceVersions[key]
+	if !ok {
+		return true
+	}
+	if rv == rt.ResourceVersion {
+		// the informer caught up
+		delete(c.resourceVersions, key)
+		return true
+	}
+	return false
+}
+
+func (c *cachedRouteTableClient) setResourceVersion(rt *networkv2.RouteTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resourceVersions[types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}] = rt.ResourceVersion
+}
+
+// setStale forces the next read of rt to the live client
+func (c *cachedRouteTableClient) setStale(rt *networkv2.RouteTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// no resourceVersion is empty, so the informer's copy never matches until a live read records one
+	c.resourceVersions[types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}] = ""
+}
+
+// updateResourceVersion records a resourceVersion read from the live client if one was already being tracked
+func (c *cachedRouteTableClient) updateResourceVersion(rt *networkv2.RouteTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}
+	if _, ok := c.resourceVersions[key]; ok {
+		c.resourceVersions[key] = rt.ResourceVersion
+	}
+}
@@ -0,0 +1,162 @@
+package gloo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeInformerCache serves reads from the RouteTables it was given, like an informer that only sees updates when
+// the test says so
+type fakeInformerCache struct {
+	cache.Cache
+	rts map[types.NamespacedName]*networkv2.RouteTable
+}
+
+func (c *fakeInformerCache) Get(_ context.Context, key k8sclient.ObjectKey, obj k8sclient.Object, _ ...k8sclient.GetOption) error {
+	rt, ok := c.rts[key]
+	if !ok {
+		return apierrors.NewNotFound(networkv2.Resource("routetables"), key.Name)
+	}
+	rt.DeepCopyInto(obj.(*networkv2.RouteTable))
+	return nil
+}
+
+func (c *fakeInformerCache) List(_ context.Context, list k8sclient.ObjectList, _ ...k8sclient.ListOption) error {
+	rtl := list.(*networkv2.RouteTableList)
+	for _, rt := range c.rts {
+		rtl.Items = append(rtl.Items, networkv2.RouteTable{})
+		rt.DeepCopyInto(&rtl.Items[len(rtl.Items)-1])
+	}
+	return nil
+}
+
+// observe makes the informer see the current state of rt
+func (c *fakeInformerCache) observe(rt *networkv2.RouteTable) {
+	c.rts[types.NamespacedName{Name: rt.Name, Namespace: rt.Namespace}] = rt.DeepCopy()
+}
+
+func newTestCachedClient(t *testing.T, rt *networkv2.RouteTable) (*cachedRouteTableClient, *fakeInformerCache) {
+	t.Helper()
+	scheme, err := NewScheme()
+	assert.NoError(t, err)
+	live := &routeTableClient{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(rt.DeepCopy()).Build()}
+	informer := &fakeInformerCache{rts: map[types.NamespacedName]*networkv2.RouteTable{}}
+	informer.observe(getLiveRouteTable(t, live, rt))
+	c := &cachedRouteTableClient{
+		live:             live,
+		informerCache:    informer,
+		namespaces:       map[string]bool{"gloo-mesh": true},
+		resourceVersions: map[types.NamespacedName]string{},
+	}
+	c.synced.Store(true)
+	return c, informer
+}
+
+func getLiveRouteTable(t *testing.T, live *routeTableClient, rt *networkv2.RouteTable) *networkv2.RouteTable {
+	t.Helper()
+	current, err := live.GetRouteTable(context.Background(), rt.Name, rt.Namespace)
+	assert.NoError(t, err)
+	return current
+}
+
+// setWeight patches the weight of the first destination of the first route of rt
+func setWeight(ctx context.Context, c RouteTableClient, rt *networkv2.RouteTable, weight uint32) error {
+	patch := k8sclient.RawPatch(types.JSONPatchType, []byte(fmt.Sprintf(
+		`[{"op":"test","path":"/metadata/resourceVersion","value":%q},{"op":"replace","path":"/spec/http/0/forwardTo/destinations/0/weight","value":%d}]`,
+		rt.ResourceVersion, weight)))
+	return c.PatchRouteTable(ctx, rt, patch)
+}
+
+func TestCachedRouteTableClient(t *testing.T) {
+	ctx := context.Background()
+	c, informer := newTestCachedClient(t, newTestRouteTable())
+	weight := func(rt *networkv2.RouteTable) uint32 {
+		return rt.Spec.Http[0].GetForwardTo().Destinations[0].Weight
+	}
+
+	// reads are served by the informer
+	informer.rts[types.NamespacedName{Name: "demo", Namespace: "gloo-mesh"}].Labels = map[string]string{"from": "informer"}
+	rt, err := c.GetRouteTable(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, "informer", rt.Labels["from"])
+
+	// after a patch, reads go to the API server until the informer has seen the patched resourceVersion
+	assert.NoError(t, setWeight(ctx, c, rt, 60))
+	patchedVersion := rt.ResourceVersion
+	assert.Equal(t, map[types.NamespacedName]string{{Name: "demo", Namespace: "gloo-mesh"}: patchedVersion}, c.resourceVersions)
+	rt, err = c.GetRouteTable(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(60), weight(rt))
+	assert.Empty(t, rt.Labels)
+	rts, err := c.ListRouteTable(ctx, k8sclient.InNamespace("gloo-mesh"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(60), weight(rts[0]))
+
+	informer.observe(rt)
+	rt, err = c.GetRouteTable(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(60), weight(rt))
+	assert.Empty(t, c.resourceVersions)
+
+	// a failed patch means the RouteTable changed since it was read: it is read from the API server, and the
+	// resourceVersion read is tracked until the informer catches up
+	// another client updates the RouteTable
+	current := getLiveRouteTable(t, c.live, rt)
+	assert.NoError(t, setWeight(ctx, c.live, current, 70))
+	assert.Error(t, setWeight(ctx, c, rt, 80))
+	assert.Equal(t, map[types.NamespacedName]string{{Name: "demo", Namespace: "gloo-mesh"}: ""}, c.resourceVersions)
+	rt, err = c.GetRouteTable(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(70), weight(rt))
+	assert.Equal(t, map[types.NamespacedName]string{{Name: "demo", Namespace: "gloo-mesh"}: current.ResourceVersion}, c.resourceVersions)
+
+	// a failed apply likewise
+	informer.observe(rt)
+	_, err = c.GetRouteTable(ctx, "demo", "gloo-mesh")
+	assert.NoError(t, err)
+	assert.Empty(t, c.resourceVersions)
+	c.live = &routeTableClient{client: conflictingClient{Client: c.live.client}}
+	assert.True(t, apierrors.IsConflict(c.ApplyRouteTable(ctx, rt)))
+	assert.Equal(t, map[types.NamespacedName]string{{Name: "demo", Namespace: "gloo-mesh"}: ""}, c.resourceVersions)
+}
+
+// conflictingClient fails every patch with a conflict
+type conflictingClient struct {
+	k8sclient.Client
+}
+
+func (c conflictingClient) Patch(_ context.Context, obj k8sclient.Object, _ k8sclient.Patch, _ ...k8sclient.PatchOption) error {
+	return apierrors.NewConflict(networkv2.Resource("routetables"), obj.GetName(), fmt.Errorf("conflict with another field manager"))
+}
+
+func TestCachedRouteTableClientScope(t *testing.T) {
+	c, _ := newTestCachedClient(t, newTestRouteTable())
+	c.selector = labels.SelectorFromSet(labels.Set{"app": "demo"})
+
+	for _, tc := range []struct {
+		name    string
+		opts    []k8sclient.ListOption
+		inScope bool
+	}{
+		{"cached namespace and selector", []k8sclient.ListOption{k8sclient.InNamespace("gloo-mesh"), k8sclient.MatchingLabels{"app": "demo"}}, true},
+		{"narrower selector", []k8sclient.ListOption{k8sclient.InNamespace("gloo-mesh"), k8sclient.MatchingLabels{"app": "demo", "team": "a"}}, true},
+		{"all namespaces", []k8sclient.ListOption{k8sclient.MatchingLabels{"app": "demo"}}, false},
+		{"other namespace", []k8sclient.ListOption{k8sclient.InNamespace("other"), k8sclient.MatchingLabels{"app": "demo"}}, false},
+		{"no selector", []k8sclient.ListOption{k8sclient.InNamespace("gloo-mesh")}, false},
+		{"other selector", []k8sclient.ListOption{k8sclient.InNamespace("gloo-mesh"), k8sclient.MatchingLabels{"app": "other"}}, false},
+	} {
+		listOpts := &k8sclient.ListOptions{}
+		listOpts.ApplyOptions(tc.opts)
+		assert.Equal(t, tc.inScope, c.inListScope(listOpts), tc.name)
+	}
+}
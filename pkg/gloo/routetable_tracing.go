@@ -0,0 +1,64 @@
+package gloo
+
+import (
+	"context"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/tracing"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tracedRouteTableClient records a span for each call to the wrapped RouteTableClient
+type tracedRouteTableClient struct {
+	RouteTableClient
+}
+
+func (c tracedRouteTableClient) GetRouteTable(ctx context.Context, name string, namespace string) (*networkv2.RouteTable, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "RouteTable.Get", trace.WithAttributes(
+		attribute.String("routetable.namespace", namespace),
+		attribute.String("routetable.name", name),
+	))
+	rt, err := c.RouteTableClient.GetRouteTable(ctx, name, namespace)
+	tracing.End(span, err)
+	return rt, err
+}
+
+func (c tracedRouteTableClient) ListRouteTable(ctx context.Context, opts ...k8sclient.ListOption) ([]*networkv2.RouteTable, error) {
+	listOpts := &k8sclient.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	attrs := []attribute.KeyValue{
+		attribute.String("routetable.namespace", listOpts.Namespace),
+	}
+	if listOpts.LabelSelector != nil {
+		attrs = append(attrs, attribute.String("routetable.selector", listOpts.LabelSelector.String()))
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "RouteTable.List", trace.WithAttributes(attrs...))
+	rts, err := c.RouteTableClient.ListRouteTable(ctx, opts...)
+	span.SetAttributes(attribute.Int("routetable.count", len(rts)))
+	tracing.End(span, err)
+	return rts, err
+}
+
+func (c tracedRouteTableClient) PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	ctx, span := tracing.Tracer().Start(ctx, "RouteTable.Patch", trace.WithAttributes(
+		attribute.String("routetable.namespace", obj.Namespace),
+		attribute.String("routetable.name", obj.Name),
+		attribute.String("patch.type", string(patch.Type())),
+	))
+	err := c.RouteTableClient.PatchRouteTable(ctx, obj, patch, opts...)
+	tracing.End(span, err)
+	return err
+}
+
+func (c tracedRouteTableClient) ApplyRouteTable(ctx context.Context, obj *networkv2.RouteTable, opts ...k8sclient.PatchOption) error {
+	ctx, span := tracing.Tracer().Start(ctx, "RouteTable.Apply", trace.WithAttributes(
+		attribute.String("routetable.namespace", obj.Namespace),
+		attribute.String("routetable.name", obj.Name),
+	))
+	err := c.RouteTableClient.ApplyRouteTable(ctx, obj, opts...)
+	tracing.End(span, err)
+	return err
+}
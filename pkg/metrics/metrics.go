@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "glooplatform_rollouts_plugin"
+
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	routeWeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "route_weight",
+		Help:      "Weight of the stable or canary destination of a route managed for a Rollout, as last written by the plugin.",
+	}, []string{"namespace", "rollout", "routetable_namespace", "routetable", "route", "destination"})
+
+	calls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "calls_total",
+		Help:      "Plugin calls by method and result.",
+	}, []string{"method", "result"})
+
+	patchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "routetable_patch_duration_seconds",
+		Help:      "Latency of RouteTable patches by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	matchedRouteTables = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "matched_routetables",
+		Help:      "RouteTables matched for a Rollout by the last call.",
+	}, []string{"namespace", "rollout"})
+
+	matchedRoutes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "matched_routes",
+		Help:      "Routes matched for a Rollout by the last call.",
+	}, []string{"namespace", "rollout"})
+
+	patchConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "routetable_patch_conflicts_total",
+		Help:      "RouteTable patches that failed because the RouteTable changed after it was read.",
+	})
+
+	patchRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "routetable_patch_retries_total",
+		Help:      "RouteTable patches retried after a conflict.",
+	})
+)
+
+// Registry holds the plugin metrics; it is separate from the default registry so that only plugin metrics
+// (and the Go and process collectors) are served
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		routeWeight,
+		calls,
+		patchDuration,
+		matchedRouteTables,
+		matchedRoutes,
+		patchConflicts,
+		patchRetries,
+	)
+}
+
+// Serve serves the plugin metrics on addr at /metrics until the server fails
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ObserveCall counts a plugin call
+func ObserveCall(method string, failed bool) {
+	calls.WithLabelValues(method, result(failed)).Inc()
+}
+
+// ObservePatch records the latency of a RouteTable write
+func ObservePatch(start time.Time, err error) {
+	patchDuration.WithLabelValues(result(err != nil)).Observe(time.Since(start).Seconds())
+}
+
+// SetMatched records the RouteTables and routes matched for a Rollout
+func SetMatched(rolloutNamespace, rolloutName string, routeTables, routes int) {
+	matchedRouteTables.WithLabelValues(rolloutNamespace, rolloutName).Set(float64(routeTables))
+	matchedRoutes.WithLabelValues(rolloutNamespace, rolloutName).Set(float64(routes))
+}
+
+// SetRouteWeights records the stable and canary weights of a route managed for a Rollout
+func SetRouteWeights(rolloutNamespace, rolloutName, routeTableNamespace, routeTableName, route string, stable, canary uint32) {
+	routeWeight.WithLabelValues(rolloutNamespace, rolloutName, routeTableNamespace, routeTableName, route, "stable").Set(float64(stable))
+	routeWeight.WithLabelValues(rolloutNamespace, rolloutName, routeTableNamespace, routeTableName, route, "canary").Set(float64(canary))
+}
+
+// DeleteRollout drops the per-Rollout series of a Rollout that no longer uses the plugin
+func DeleteRollout(rolloutNamespace, rolloutName string) {
+	labels := prometheus.Labels{"namespace": rolloutNamespace, "rollout": rolloutName}
+	routeWeight.DeletePartialMatch(labels)
+	matchedRouteTables.DeletePartialMatch(labels)
+	matchedRoutes.DeletePartialMatch(labels)
+}
+
+// IncPatchConflicts counts a RouteTable patch conflict
+func IncPatchConflicts() {
+	patchConflicts.Inc()
+}
+
+// IncPatchRetries counts a retried RouteTable patch
+func IncPatchRetries() {
+	patchRetries.Inc()
+}
+
+func result(failed bool) string {
+	if failed {
+		return ResultError
+	}
+	return ResultSuccess
+}
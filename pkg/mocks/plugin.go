@@ -2,44 +2,37 @@ package mocks
 
 import (
 	"context"
-	"fmt"
+	"net/http"
 
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
-	gloov2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
-func NewGlooMockClient(routeTables []*gloov2.RouteTable) gloo.NetworkV2ClientSet {
-	return &GlooMockClient{
-		rtClient: &glooMockRouteTableClient{
-			routeTables: routeTables,
-		},
+// NewGlooFakeClient creates a client set backed by a controller-runtime fake client with the Gloo Platform APIs
+// registered, serving objs; objs may be of any Gloo type supported by gloo.NetworkV2ClientSet. Patches and applies
+// update the stored objects, so their result can be read back through the client set.
+func NewGlooFakeClient(objs ...k8sclient.Object) gloo.NetworkV2ClientSet {
+	scheme, err := gloo.NewScheme()
+	if err != nil {
+		panic(err)
 	}
+	return gloo.NewNetworkV2ClientSetForClient(jsonPatchErrorsClient{fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()})
 }
 
-type GlooMockClient struct {
-	rtClient *glooMockRouteTableClient
+// jsonPatchErrorsClient reports JSON patches that fail to apply the way the API server does, as an invalid request
+// without causes; the fake client returns the error of the JSON patch library as is.
+type jsonPatchErrorsClient struct {
+	k8sclient.Client
 }
 
-func (c GlooMockClient) RouteTables() gloo.RouteTableClient {
-	return c.rtClient
-}
-
-type glooMockRouteTableClient struct {
-	routeTables []*gloov2.RouteTable
-}
-
-func (c glooMockRouteTableClient) GetRouteTable(ctx context.Context, name string, namespace string) (*gloov2.RouteTable, error) {
-	if len(c.routeTables) > 0 {
-		return c.routeTables[0], nil
+func (c jsonPatchErrorsClient) Patch(ctx context.Context, obj k8sclient.Object, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	if _, ok := err.(apierrors.APIStatus); err != nil && !ok && patch.Type() == types.JSONPatchType {
+		return apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "", schema.GroupResource{}, "", err.Error(), 0, false)
 	}
-	return nil, fmt.Errorf("routeTable not found: %s:%s", namespace, name)
-}
-
-func (c glooMockRouteTableClient) PatchRouteTable(ctx context.Context, obj *gloov2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
-	return nil
-}
-
-func (c glooMockRouteTableClient) ListRouteTable(ctx context.Context, opts ...k8sclient.ListOption) ([]*gloov2.RouteTable, error) {
-	return c.routeTables, nil
+	return err
 }
@@ -2,17 +2,26 @@ package plugin
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/metrics"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/util"
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	rolloutsclientset "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned"
 	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
 	"github.com/sirupsen/logrus"
 	solov2 "github.com/solo-io/solo-apis/client-go/common.gloo.solo.io/v2"
 	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -20,19 +29,72 @@ const (
 	Type                       = "GlooPlatformAPI"
 	GlooPlatformAPIUpdateError = "GlooPlatformAPIUpdateError"
 	PluginName                 = "solo-io/glooplatform"
+
+	DefaultMaxTrafficWeight = 100
 )
 
 type RpcPlugin struct {
-	IsTest bool
-	// temporary hack until mock clienset is fixed (missing some interface methods)
-	// TestRouteTable *networkv2.RouteTable
-	LogCtx *logrus.Entry
+	LogCtx   *logrus.Entry
+	Settings *config.Settings
+	// client set for the default target cluster; InitPlugin creates the clients left nil
 	Client gloo.NetworkV2ClientSet
+	// client for the cluster running Argo Rollouts
+	KubeClient kubernetes.Interface
+	// client for Rollouts in the cluster running Argo Rollouts
+	RolloutsClient rolloutsclientset.Interface
+	// records Events on Rollouts
+	Recorder record.EventRecorder
+	// client sets for target clusters resolved from kubeconfig files and Secrets
+	clientSets *gloo.ClientSetCache
+	// defaults of the plugin config; read from the ConfigMap configured in the plugin-wide settings
+	Defaults *ConfigDefaults
+	// dry run patches already recorded in Events
+	dryRunPatches dryRunPatches
+	// stable and canary Services found per Rollout
+	validatedServices validatedServices
 }
 
 type GlooPlatformAPITrafficRouting struct {
-	RouteTableSelector *DumbObjectSelector `json:"routeTableSelector" protobuf:"bytes,1,name=routeTableSelector"`
-	RouteSelector      *DumbRouteSelector  `json:"routeSelector" protobuf:"bytes,2,name=routeSelector"`
+	RouteTableSelector *DumbObjectSelector   `json:"routeTableSelector" protobuf:"bytes,1,name=routeTableSelector"`
+	RouteSelector      *DumbRouteSelector    `json:"routeSelector" protobuf:"bytes,2,name=routeSelector"`
+	ManagementCluster  *ManagementClusterRef `json:"managementCluster" protobuf:"bytes,3,name=managementCluster"`
+	// write RouteTables with server-side apply instead of a merge patch
+	ServerSideApply bool `json:"serverSideApply" protobuf:"varint,4,opt,name=serverSideApply"`
+	// with serverSideApply, take ownership of spec.http from other field managers instead of failing the step
+	ForceOwnership bool `json:"forceOwnership" protobuf:"varint,10,opt,name=forceOwnership"`
+	// action taken when the weights of a RouteTable drift from the weights last applied (reapply, verify or fail);
+	// defaults to the plugin-wide setting
+	DriftAction string `json:"driftAction" protobuf:"bytes,5,opt,name=driftAction" jsonschema:"enum=reapply|verify|fail"`
+	// compute and record RouteTable patches without sending them; defaults to the plugin-wide setting
+	DryRun *bool `json:"dryRun" protobuf:"varint,6,opt,name=dryRun"`
+	// total weight of the stable and canary destinations, which desired weights are relative to; defaults to 100.
+	// Argo Rollouts v1.5 limits the weight of each step to 100, so a larger total only allows canaries below 1%.
+	MaxTrafficWeight int32 `json:"maxTrafficWeight" protobuf:"varint,7,opt,name=maxTrafficWeight" jsonschema:"minimum=0"`
+	// collapse the matched routes back to a single destination once the Rollout is fully promoted
+	CollapseAfterPromotion *CollapseAfterPromotion `json:"collapseAfterPromotion" protobuf:"bytes,8,opt,name=collapseAfterPromotion"`
+	// roll back the RouteTables already updated by SetWeight if updating another matched RouteTable fails
+	Atomic bool `json:"atomic" protobuf:"varint,9,opt,name=atomic"`
+}
+
+// CollapseAfterPromotion configures removing the destination left without weight from the matched routes once the
+// Rollout is fully promoted
+type CollapseAfterPromotion struct {
+	Enabled bool `json:"enabled" protobuf:"varint,1,opt,name=enabled"`
+	// also remove the weight of the remaining destination when it is the only destination of the route
+	RemoveWeight bool `json:"removeWeight" protobuf:"varint,2,opt,name=removeWeight"`
+}
+
+// ManagementClusterRef selects the cluster hosting the Gloo Platform RouteTables when it is not the
+// cluster running Argo Rollouts
+type ManagementClusterRef struct {
+	KubeConfigPath      string        `json:"kubeConfigPath" protobuf:"bytes,1,name=kubeConfigPath"`
+	KubeConfigSecretRef *SecretKeyRef `json:"kubeConfigSecretRef" protobuf:"bytes,2,name=kubeConfigSecretRef"`
+}
+
+// SecretKeyRef refers to a key within a Secret in the Rollout namespace
+type SecretKeyRef struct {
+	Name string `json:"name" protobuf:"bytes,1,name=name"`
+	Key  string `json:"key" protobuf:"bytes,2,name=key"`
 }
 
 type DumbObjectSelector struct {
@@ -88,43 +150,137 @@ type GlooMatchedTCPRoutes struct {
 	Destinations []*GlooDestinations
 }
 
-func (r *RpcPlugin) InitPlugin() pluginTypes.RpcError {
-	if r.IsTest {
-		return pluginTypes.RpcError{}
+func (r *RpcPlugin) InitPlugin() (rpcErr pluginTypes.RpcError) {
+	_, span := startSpan(context.Background(), "InitPlugin", nil)
+	defer func() { endSpan(span, rpcErr) }()
+
+	if r.Settings == nil {
+		r.Settings = &config.Settings{}
 	}
-	client, err := gloo.NewNetworkV2ClientSet()
-	if err != nil {
+	r.clientSets = gloo.NewClientSetCache(r.clientOptions()...)
+
+	if r.KubeClient == nil {
+		kubeClient, err := util.GetKubernetesClient()
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+		r.KubeClient = kubeClient
+	}
+
+	if r.RolloutsClient == nil {
+		rolloutsClient, err := util.GetRolloutsClient()
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+		r.RolloutsClient = rolloutsClient
+	}
+
+	if r.Recorder == nil {
+		recorder, err := util.NewEventRecorder(r.KubeClient, gloo.FieldManager)
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+		r.Recorder = recorder
+	}
+
+	if err := r.loadConfigDefaults(context.Background()); err != nil {
 		return pluginTypes.RpcError{
 			ErrorString: err.Error(),
 		}
 	}
+
+	if r.Client != nil {
+		return pluginTypes.RpcError{}
+	}
+	// a default client set backed by a Secret is resolved on each call so that kubeconfig rotation is honored
+	if r.Settings.KubeConfigSecret != nil {
+		return pluginTypes.RpcError{}
+	}
+
+	var client gloo.NetworkV2ClientSet
+	var err error
+	if r.Settings.KubeConfigPath != "" {
+		cfg, err := util.GetKubeConfigFromFile(r.Settings.KubeConfigPath)
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: fmt.Sprintf("failed to load kubeconfig %s: %s", r.Settings.KubeConfigPath, err),
+			}
+		}
+		client, err = gloo.NewNetworkV2ClientSetForConfig(cfg, r.clientOptions()...)
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+	} else {
+		client, err = gloo.NewNetworkV2ClientSet(r.clientOptions()...)
+		if err != nil {
+			return pluginTypes.RpcError{
+				ErrorString: err.Error(),
+			}
+		}
+	}
 	r.Client = client
 	return pluginTypes.RpcError{}
 }
 
 func (r *RpcPlugin) UpdateHash(rollout *v1alpha1.Rollout, canaryHash, stableHash string, additionalDestinations []v1alpha1.WeightDestination) pluginTypes.RpcError {
+	_, span := startSpan(context.Background(), "UpdateHash", rollout)
+	defer span.End()
 	return pluginTypes.RpcError{}
 }
 
-func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) pluginTypes.RpcError {
-	ctx := context.TODO()
-	glooPluginConfig, err := getPluginConfig(rollout)
+func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) (rpcErr pluginTypes.RpcError) {
+	defer func() { metrics.ObserveCall("SetWeight", rpcErr.HasError()) }()
+
+	ctx, cancel := r.newContext(rollout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "SetWeight", rollout, attribute.Int("weight.desired", int(desiredWeight)))
+	defer func() { endSpan(span, rpcErr) }()
+
+	glooPluginConfig, err := r.getValidatedPluginConfig(ctx, rollout)
 	if err != nil {
+		return rpcError(ctx, err)
+	}
+
+	if desiredWeight < 0 || desiredWeight > glooPluginConfig.maxTrafficWeight() {
 		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
+			ErrorString: fmt.Sprintf("desired weight %d is out of range [0, %d]", desiredWeight, glooPluginConfig.maxTrafficWeight()),
 		}
 	}
 
+	client, err := r.getClient(ctx, rollout, glooPluginConfig)
+	if err != nil {
+		return rpcError(ctx, err)
+	}
+
 	// get the matched routetables
-	matchedRts, err := r.getRouteTables(ctx, rollout, glooPluginConfig)
+	matchedRts, err := r.getRouteTables(ctx, client, rollout, glooPluginConfig)
 	if err != nil {
-		return pluginTypes.RpcError{
-			ErrorString: err.Error(),
-		}
+		return rpcError(ctx, err)
+	}
+	// a fully promoted Rollout set to 0 after RemoveManagedRoutes no longer manages the routes, so its series
+	// are not recreated
+	if claimsRoutes(rollout, desiredWeight) {
+		observeMatched(rollout, matchedRts)
 	}
+	span.SetAttributes(matchedRouteTablesAttribute(matchedRts))
 
 	if rollout.Spec.Strategy.Canary != nil {
-		return r.handleCanary(ctx, rollout, desiredWeight, additionalDestinations, glooPluginConfig, matchedRts)
+		matchedRts, err = r.handleDrift(ctx, client, rollout, glooPluginConfig, matchedRts)
+		if err != nil {
+			return rpcError(ctx, err)
+		}
+		if err := r.handleCanary(ctx, client, rollout, desiredWeight, additionalDestinations, glooPluginConfig, matchedRts); err != nil {
+			return rpcError(ctx, err)
+		}
+		return pluginTypes.RpcError{}
 	} else if rollout.Spec.Strategy.BlueGreen != nil {
 		return r.handleBlueGreen(rollout)
 	}
@@ -133,19 +289,111 @@ func (r *RpcPlugin) SetWeight(rollout *v1alpha1.Rollout, desiredWeight int32, ad
 }
 
 func (r *RpcPlugin) SetHeaderRoute(rollout *v1alpha1.Rollout, headerRouting *v1alpha1.SetHeaderRoute) pluginTypes.RpcError {
+	_, span := startSpan(context.Background(), "SetHeaderRoute", rollout)
+	defer span.End()
+	metrics.ObserveCall("SetHeaderRoute", false)
 	return pluginTypes.RpcError{}
 }
 
 func (r *RpcPlugin) SetMirrorRoute(rollout *v1alpha1.Rollout, setMirrorRoute *v1alpha1.SetMirrorRoute) pluginTypes.RpcError {
+	_, span := startSpan(context.Background(), "SetMirrorRoute", rollout)
+	defer span.End()
 	return pluginTypes.RpcError{}
 }
 
-func (r *RpcPlugin) VerifyWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) (pluginTypes.RpcVerified, pluginTypes.RpcError) {
+// VerifyWeight reports drift from the weights last applied when the drift action of the Rollout is verify; the
+// weights are otherwise always verified.
+func (r *RpcPlugin) VerifyWeight(rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination) (verified pluginTypes.RpcVerified, rpcErr pluginTypes.RpcError) {
+	defer func() { metrics.ObserveCall("VerifyWeight", rpcErr.HasError()) }()
+
+	ctx, cancel := r.newContext(rollout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "VerifyWeight", rollout, attribute.Int("weight.desired", int(desiredWeight)))
+	defer func() { endSpan(span, rpcErr) }()
+
+	if rollout.Spec.Strategy.Canary == nil {
+		return pluginTypes.Verified, pluginTypes.RpcError{}
+	}
+	glooPluginConfig, err := r.getPluginConfig(rollout)
+	if err != nil {
+		return pluginTypes.NotVerified, pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
+	if r.driftAction(glooPluginConfig) != config.DriftActionVerify {
+		return pluginTypes.Verified, pluginTypes.RpcError{}
+	}
+
+	client, err := r.getClient(ctx, rollout, glooPluginConfig)
+	if err != nil {
+		return pluginTypes.NotVerified, rpcError(ctx, err)
+	}
+	matchedRts, err := r.getRouteTables(ctx, client, rollout, glooPluginConfig)
+	if err != nil {
+		return pluginTypes.NotVerified, rpcError(ctx, err)
+	}
+	for _, rt := range matchedRts {
+		if drifts := r.detectDrift(rt); len(drifts) > 0 {
+			r.logCtx(ctx).Infof("weights of route table %s.%s drifted; not verified", rt.RouteTable.Namespace, rt.RouteTable.Name)
+			return pluginTypes.NotVerified, pluginTypes.RpcError{}
+		}
+	}
 	return pluginTypes.Verified, pluginTypes.RpcError{}
 }
 
-func (r *RpcPlugin) RemoveManagedRoutes(rollout *v1alpha1.Rollout) pluginTypes.RpcError {
-	// we could remove the canary destination, but not required since it will have 0 weight at the end of rollout
+func (r *RpcPlugin) RemoveManagedRoutes(rollout *v1alpha1.Rollout) (rpcErr pluginTypes.RpcError) {
+	ctx, cancel := r.newContext(rollout)
+	defer cancel()
+	ctx, span := startSpan(ctx, "RemoveManagedRoutes", rollout)
+	defer func() { endSpan(span, rpcErr) }()
+
+	// the canary destination is left in place with 0 weight at the end of the rollout unless collapseAfterPromotion
+	// is enabled
+	metrics.DeleteRollout(rollout.Namespace, rollout.Name)
+	if rollout.Spec.Strategy.Canary == nil {
+		return pluginTypes.RpcError{}
+	}
+
+	glooPluginConfig, err := r.getPluginConfig(rollout)
+	if err != nil {
+		return pluginTypes.RpcError{
+			ErrorString: err.Error(),
+		}
+	}
+	client, err := r.getClient(ctx, rollout, glooPluginConfig)
+	if err != nil {
+		return rpcError(ctx, err)
+	}
+	matchedRts, err := r.getRouteTables(ctx, client, rollout, glooPluginConfig)
+	if err != nil {
+		return rpcError(ctx, err)
+	}
+
+	// release the routes claimed by the Rollout so that another Rollout may manage them and, if enabled, collapse
+	// the routes of a fully promoted Rollout
+	collapse := glooPluginConfig.collapse(rollout)
+	results := r.forEachRouteTable(matchedRts, false, func(i int, rt *GlooMatchedRouteTable) error {
+		var collapsed []string
+		if _, err := r.updateRouteTable(ctx, client, rollout, glooPluginConfig, rt, func(rt *GlooMatchedRouteTable) error {
+			if collapse {
+				collapsed = collapseRoutes(rt, glooPluginConfig.CollapseAfterPromotion.RemoveWeight)
+			}
+			return r.releaseRoutes(rollout, rt)
+		}); err != nil {
+			return fmt.Errorf("failed to release routes of RouteTable %s.%s: %w", rt.RouteTable.Namespace, rt.RouteTable.Name, err)
+		}
+		if r.dryRun(glooPluginConfig) {
+			return nil
+		}
+		for _, msg := range collapsed {
+			r.logCtx(ctx).Info(msg)
+			r.recordEvent(ctx, client, rollout, rt.RouteTable, corev1.EventTypeNormal, EventReasonRoutesCollapsed, msg)
+		}
+		return nil
+	})
+	if err := utilerrors.NewAggregate(routeTableErrors(results)); err != nil {
+		return rpcError(ctx, err)
+	}
 	return pluginTypes.RpcError{}
 }
 
@@ -153,37 +401,101 @@ func (r *RpcPlugin) Type() string {
 	return Type
 }
 
-func getPluginConfig(rollout *v1alpha1.Rollout) (*GlooPlatformAPITrafficRouting, error) {
-	glooplatformConfig := GlooPlatformAPITrafficRouting{}
+// newContext returns the context for a single plugin call made for rollout, bounded by the configured request
+// timeout and carrying the logger of the call
+func (r *RpcPlugin) newContext(rollout *v1alpha1.Rollout) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(context.Background(), logCtxKey{}, r.rolloutLogCtx(rollout))
+	if r.Settings == nil || r.Settings.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.Settings.RequestTimeout)
+}
 
-	err := json.Unmarshal(rollout.Spec.Strategy.Canary.TrafficRouting.Plugins[PluginName], &glooplatformConfig)
-	if err != nil {
+// rpcError converts err to an RpcError; errors caused by the call running out of time are reported as such
+// so they can be told apart from errors returned by the API server
+func rpcError(ctx context.Context, err error) pluginTypes.RpcError {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return pluginTypes.RpcError{
+			ErrorString: fmt.Sprintf("timed out talking to Gloo API: %s", err),
+		}
+	}
+	return pluginTypes.RpcError{
+		ErrorString: err.Error(),
+	}
+}
+
+// observeMatched records the RouteTables and routes matched for rollout
+func observeMatched(rollout *v1alpha1.Rollout, matchedRts []*GlooMatchedRouteTable) {
+	routes := 0
+	for _, rt := range matchedRts {
+		routes += len(rt.HttpRoutes)
+	}
+	metrics.SetMatched(rollout.Namespace, rollout.Name, len(matchedRts), routes)
+}
+
+// getPluginConfig decodes the plugin config of rollout, merged over the config defaults, renders its selector
+// templates and validates it; every problem found is reported in the returned error
+func (r *RpcPlugin) getPluginConfig(rollout *v1alpha1.Rollout) (*GlooPlatformAPITrafficRouting, error) {
+	glooplatformConfig, problems := r.parsePluginConfig(rollout)
+	if err := configError(problems); err != nil {
+		return nil, err
+	}
+	return glooplatformConfig, nil
+}
+
+// getValidatedPluginConfig is getPluginConfig, also checking that the stable and canary Services of rollout exist
+func (r *RpcPlugin) getValidatedPluginConfig(ctx context.Context, rollout *v1alpha1.Rollout) (*GlooPlatformAPITrafficRouting, error) {
+	glooplatformConfig, problems := r.parsePluginConfig(rollout)
+	problems = append(problems, r.validateServices(ctx, rollout)...)
+	if err := configError(problems); err != nil {
 		return nil, err
 	}
+	return glooplatformConfig, nil
+}
 
-	return &glooplatformConfig, nil
+func (r *RpcPlugin) parsePluginConfig(rollout *v1alpha1.Rollout) (*GlooPlatformAPITrafficRouting, []string) {
+	data, err := r.Defaults.apply(rollout.Namespace, rollout.Spec.Strategy.Canary.TrafficRouting.Plugins[PluginName])
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	glooplatformConfig, problems := decodePluginConfig(data)
+	if glooplatformConfig != nil {
+		renderProblems, unrendered := glooplatformConfig.renderSelectors(rollout)
+		problems = append(problems, renderProblems...)
+		problems = append(problems, glooplatformConfig.validate(unrendered)...)
+	}
+	return glooplatformConfig, problems
+}
+
+// maxTrafficWeight returns the total weight of the stable and canary destinations
+func (c *GlooPlatformAPITrafficRouting) maxTrafficWeight() int32 {
+	if c.MaxTrafficWeight > 0 {
+		return c.MaxTrafficWeight
+	}
+	return DefaultMaxTrafficWeight
 }
 
-func (r *RpcPlugin) getRouteTables(ctx context.Context, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting) ([]*GlooMatchedRouteTable, error) {
+func (r *RpcPlugin) getRouteTables(ctx context.Context, client gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting) ([]*GlooMatchedRouteTable, error) {
 	if glooPluginConfig.RouteTableSelector == nil {
 		return nil, fmt.Errorf("routeTable selector is required")
 	}
+	logCtx := r.logCtx(ctx)
 
 	if strings.EqualFold(glooPluginConfig.RouteTableSelector.Namespace, "") {
-		r.LogCtx.Debugf("defaulting routeTableSelector namespace to Rollout namespace %s for rollout %s", rollout.Namespace, rollout.Name)
+		logCtx.Debugf("defaulting routeTableSelector namespace to Rollout namespace %s", rollout.Namespace)
 		glooPluginConfig.RouteTableSelector.Namespace = rollout.Namespace
 	}
 
 	var rts []*networkv2.RouteTable
 
 	if !strings.EqualFold(glooPluginConfig.RouteTableSelector.Name, "") {
-		r.LogCtx.Debugf("getRouteTables using ns:name ref %s:%s to get single table", glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
-		result, err := r.Client.RouteTables().GetRouteTable(ctx, glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
+		logCtx.Debugf("getRouteTables using ns:name ref %s:%s to get single table", glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
+		result, err := client.RouteTables().GetRouteTable(ctx, glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
 		if err != nil {
 			return nil, err
 		}
 
-		r.LogCtx.Debugf("getRouteTables using ns:name ref %s:%s found 1 table", glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
+		logCtx.Debugf("getRouteTables using ns:name ref %s:%s found 1 table", glooPluginConfig.RouteTableSelector.Name, glooPluginConfig.RouteTableSelector.Namespace)
 		rts = append(rts, result)
 	} else {
 		opts := &k8sclient.ListOptions{}
@@ -195,14 +507,14 @@ func (r *RpcPlugin) getRouteTables(ctx context.Context, rollout *v1alpha1.Rollou
 			opts.Namespace = glooPluginConfig.RouteTableSelector.Namespace
 		}
 
-		r.LogCtx.Debugf("getRouteTables listing tables with opts %+v", opts)
+		logCtx.Debugf("getRouteTables listing tables with opts %+v", opts)
 		var err error
 
-		rts, err = r.Client.RouteTables().ListRouteTable(ctx, opts)
+		rts, err = client.RouteTables().ListRouteTable(ctx, opts)
 		if err != nil {
 			return nil, err
 		}
-		r.LogCtx.Debugf("getRouteTables listing tables with opts %+v; found %d routeTables", opts, len(rts))
+		logCtx.Debugf("getRouteTables listing tables with opts %+v; found %d routeTables", opts, len(rts))
 	}
 
 	matched := []*GlooMatchedRouteTable{}
@@ -212,7 +524,7 @@ func (r *RpcPlugin) getRouteTables(ctx context.Context, rollout *v1alpha1.Rollou
 			RouteTable: rt,
 		}
 		// destination matching
-		if err := matchedRt.matchRoutes(r.LogCtx, rollout, glooPluginConfig); err != nil {
+		if err := matchedRt.matchRoutes(logCtx, rollout, glooPluginConfig); err != nil {
 			return nil, err
 		}
 
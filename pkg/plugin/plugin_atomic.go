@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// updatedRouteTable is a matched RouteTable updated by handleCanary, along with its state from before and after the
+// update
+type updatedRouteTable struct {
+	rt *GlooMatchedRouteTable
+	// the RouteTable the successful write was based on; the RouteTable is read again before each retry
+	snapshot *networkv2.RouteTable
+	// the RouteTable as written
+	updated *networkv2.RouteTable
+	// descriptions of the routes whose weights were normalized
+	normalized []string
+	// whether the update claimed the matched routes for the Rollout; see claimsRoutes
+	claimed bool
+}
+
+// patched returns true if the update wrote the RouteTable rather than finding it up to date
+func (u *updatedRouteTable) patched() bool {
+	return u.updated.ResourceVersion != u.snapshot.ResourceVersion
+}
+
+// rollbackRouteTables restores the updated RouteTables to their snapshots after updating others failed with
+// updateErr, so that the matched RouteTables are either all updated or none are. updates and results are in the
+// order of matchedRts; updates are nil for the RouteTables that were not updated. The returned error wraps updateErr
+// and states the final state of every matched RouteTable.
+//
+// The update may have failed because the plugin call ran out of time, so the rollback gets a deadline of its own.
+func (r *RpcPlugin) rollbackRouteTables(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting, matchedRts []*GlooMatchedRouteTable, results []routeTableResult, updates []*updatedRouteTable, updateErr error) error {
+	rollbackCtx, cancel := r.newContext(rollout)
+	defer cancel()
+	ctx = trace.ContextWithSpan(rollbackCtx, trace.SpanFromContext(ctx))
+
+	states := make([]string, len(matchedRts))
+	var live []*updatedRouteTable
+	var mu sync.Mutex
+	r.forEachRouteTable(matchedRts, false, func(i int, rt *GlooMatchedRouteTable) error {
+		switch {
+		case !results[i].started:
+			states[i] = "not updated"
+			return nil
+		case results[i].err != nil:
+			states[i] = "unchanged"
+			return nil
+		}
+
+		var changes []routeWeightChange
+		restore, err := r.restoreRouteTable(ctx, rollout, glooPluginConfig, updates[i])
+		if err == nil {
+			changes, err = r.updateRouteTable(ctx, glooClient, rollout, glooPluginConfig, rt, restore)
+			r.recordWeightChanges(ctx, glooClient, rollout, rt.RouteTable, changes, err)
+		}
+		if err != nil {
+			r.logCtx(ctx).Errorf("failed to roll back route table %s.%s: %s", rt.RouteTable.Namespace, rt.RouteTable.Name, err)
+			states[i] = fmt.Sprintf("updated, rollback failed: %s", err)
+			mu.Lock()
+			live = append(live, updates[i])
+			mu.Unlock()
+			return err
+		}
+		r.logCtx(ctx).Infof("rolled back route table %s.%s", rt.RouteTable.Namespace, rt.RouteTable.Name)
+		states[i] = "rolled back"
+		return nil
+	})
+	// the weights of RouteTables that could not be rolled back are live, so their metrics are recorded
+	r.completeCanaryUpdates(ctx, glooClient, rollout, live)
+
+	var described []string
+	for i, rt := range matchedRts {
+		described = append(described, fmt.Sprintf("%s.%s %s", rt.RouteTable.Namespace, rt.RouteTable.Name, states[i]))
+	}
+	return fmt.Errorf("%w; RouteTables: %s", updateErr, strings.Join(described, ", "))
+}
+
+// restoreRouteTable returns a mutate func reverting the changes made by the update u to the spec and state
+// annotations of a RouteTable and matching its routes again. The changes are reverted with a JSON patch testing
+// the values written by the update, so that the RouteTable is not restored, and the rollback fails, if any of them
+// changed since; changes made by others to other fields are kept.
+func (r *RpcPlugin) restoreRouteTable(ctx context.Context, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting, u *updatedRouteTable) (func(rt *GlooMatchedRouteTable) error, error) {
+	updated := u.updated.DeepCopy()
+	// the RouteTable is read again before a retry, so its resourceVersion may differ
+	updated.ResourceVersion = ""
+	restored := updated.DeepCopy()
+	u.snapshot.Spec.DeepCopyInto(&restored.Spec)
+	if restored.Annotations == nil {
+		restored.Annotations = map[string]string{}
+	}
+	for _, key := range stateAnnotations {
+		if v, ok := u.snapshot.Annotations[key]; ok {
+			restored.Annotations[key] = v
+		} else {
+			delete(restored.Annotations, key)
+		}
+	}
+	patch, _, err := gloo.BuildRouteTablePatch(updated, restored, gloo.WithSpec(), gloo.WithAnnotations(), gloo.AsJSONPatch())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build rollback patch: %s", err)
+	}
+	revert, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build rollback patch: %s", err)
+	}
+
+	return func(rt *GlooMatchedRouteTable) error {
+		data, err := json.Marshal(rt.RouteTable)
+		if err != nil {
+			return err
+		}
+		data, err = revert.Apply(data)
+		if err != nil {
+			// reported as a conflict, so that the rollback is retried on a fresh read of the RouteTable
+			return apierrors.NewConflict(networkv2.Resource("routetables"), rt.RouteTable.Name, fmt.Errorf("RouteTable changed since it was updated: %s", err))
+		}
+		reverted := &networkv2.RouteTable{}
+		if err := json.Unmarshal(data, reverted); err != nil {
+			return err
+		}
+
+		rt.RouteTable = reverted
+		rt.HttpRoutes, rt.TCPRoutes, rt.TLSRoutes = nil, nil, nil
+		return rt.matchRoutes(r.logCtx(ctx), rollout, glooPluginConfig)
+	}, nil
+}
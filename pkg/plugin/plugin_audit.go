@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/argoproj/argo-rollouts/utils/annotations"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+)
+
+// Version is the plugin version, set at build time
+var Version = "dev"
+
+// annotations written on every RouteTable the plugin updates
+const (
+	AnnotationPrefix        = "glooplatform.rollouts.argoproj.io/"
+	AnnotationRollout       = AnnotationPrefix + "rollout"
+	AnnotationRevision      = AnnotationPrefix + "revision"
+	AnnotationCanaryWeights = AnnotationPrefix + "canary-weights"
+	AnnotationUpdatedAt     = AnnotationPrefix + "updated-at"
+	AnnotationPluginVersion = AnnotationPrefix + "plugin-version"
+)
+
+// pluginAnnotations are the annotations owned by the plugin
+var pluginAnnotations = []string{
+	AnnotationRollout,
+	AnnotationRevision,
+	AnnotationCanaryWeights,
+	AnnotationUpdatedAt,
+	AnnotationPluginVersion,
+	AnnotationRouteOwners,
+	AnnotationAppliedWeights,
+}
+
+// stateAnnotations are the plugin annotations holding state rather than auditing updates; they are written even if
+// the spec is unchanged and restored along with the spec on rollback
+var stateAnnotations = []string{
+	AnnotationRouteOwners,
+	AnnotationAppliedWeights,
+}
+
+// setAuditAnnotations records on the RouteTable of rt the Rollout updating it, its revision and the canary weight
+// of each matched route. The canary weights of routes differ when some routes have other destinations, so they are
+// recorded as a JSON object mapping route names to weights; unnamed routes are left out.
+func setAuditAnnotations(rollout *v1alpha1.Rollout, rt *GlooMatchedRouteTable) {
+	if rt.RouteTable.Annotations == nil {
+		rt.RouteTable.Annotations = map[string]string{}
+	}
+	a := rt.RouteTable.Annotations
+	a[AnnotationRollout] = fmt.Sprintf("%s/%s", rollout.Namespace, rollout.Name)
+	a[AnnotationRevision] = rollout.Annotations[annotations.RevisionAnnotation]
+	a[AnnotationUpdatedAt] = time.Now().UTC().Format(time.RFC3339)
+	a[AnnotationPluginVersion] = Version
+
+	canaryWeights := map[string]uint32{}
+	for _, w := range rt.routeWeights() {
+		if w.Route != "" {
+			canaryWeights[w.Route] = w.Canary
+		}
+	}
+	setMapAnnotation(rt.RouteTable, AnnotationCanaryWeights, canaryWeights)
+}
+
+// setMapAnnotation sets the annotation key of rt to m encoded as a JSON object, or removes it if m is empty. Map keys
+// are encoded in sorted order, so the value only changes with the contents of m and rewriting an unchanged map adds
+// nothing to the patch.
+func setMapAnnotation[V any](rt *networkv2.RouteTable, key string, m map[string]V) {
+	if len(m) == 0 {
+		delete(rt.Annotations, key)
+		return
+	}
+	// the annotation maps hold strings, numbers or structs of them, which always encode
+	data, _ := json.Marshal(m)
+	if rt.Annotations == nil {
+		rt.Annotations = map[string]string{}
+	}
+	rt.Annotations[key] = string(data)
+}
+
+// getPluginAnnotations returns the annotations of rt owned by the plugin
+func getPluginAnnotations(rt *GlooMatchedRouteTable) map[string]string {
+	result := map[string]string{}
+	for _, key := range pluginAnnotations {
+		if v, ok := rt.RouteTable.Annotations[key]; ok {
+			result[key] = v
+		}
+	}
+	return result
+}
@@ -4,53 +4,145 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/metrics"
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
-	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
 	solov2 "github.com/solo-io/solo-apis/client-go/common.gloo.solo.io/v2"
-	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
-func (r *RpcPlugin) handleCanary(ctx context.Context, rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, glooPluginConfig *GlooPlatformAPITrafficRouting, glooMatchedRouteTables []*GlooMatchedRouteTable) pluginTypes.RpcError {
-	remainingWeight := 100 - desiredWeight
-
-	for _, rt := range glooMatchedRouteTables {
-		// the original rt is preserved to use for patch generation
-		ogRt := &networkv2.RouteTable{}
-		rt.RouteTable.DeepCopyInto(ogRt)
-
-		// set stable and canary (create canary destination if required)
-		for _, matchedHttpRoute := range rt.HttpRoutes {
-			if matchedHttpRoute.Destinations != nil {
-				matchedHttpRoute.Destinations.StableOrActiveDestination.Weight = uint32(remainingWeight)
-
-				if matchedHttpRoute.Destinations.CanaryOrPreviewDestination == nil {
-					newDest, err := r.newCanaryDest(matchedHttpRoute.Destinations.StableOrActiveDestination, rollout)
-					if err != nil {
-						return pluginTypes.RpcError{
-							ErrorString: err.Error(),
-						}
-					}
-					matchedHttpRoute.Destinations.CanaryOrPreviewDestination = newDest
-					matchedHttpRoute.HttpRoute.GetForwardTo().Destinations = append(matchedHttpRoute.HttpRoute.GetForwardTo().Destinations, matchedHttpRoute.Destinations.CanaryOrPreviewDestination)
-				}
+func (r *RpcPlugin) handleCanary(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, desiredWeight int32, additionalDestinations []v1alpha1.WeightDestination, glooPluginConfig *GlooPlatformAPITrafficRouting, glooMatchedRouteTables []*GlooMatchedRouteTable) error {
+	dryRun := r.dryRun(glooPluginConfig)
+	atomicUpdate := glooPluginConfig.Atomic && !dryRun
+	claim := claimsRoutes(rollout, desiredWeight)
+
+	updates := make([]*updatedRouteTable, len(glooMatchedRouteTables))
+	results := r.forEachRouteTable(glooMatchedRouteTables, atomicUpdate, func(i int, rt *GlooMatchedRouteTable) error {
+		u := &updatedRouteTable{
+			rt:      rt,
+			claimed: claim,
+		}
+		changes, err := r.updateRouteTable(ctx, glooClient, rollout, glooPluginConfig, rt, func(rt *GlooMatchedRouteTable) error {
+			// mutate is applied again to a fresh read of the RouteTable on each retry
+			u.snapshot = rt.RouteTable.DeepCopy()
+			if err := r.checkRouteOwners(ctx, rollout, rt, claim); err != nil {
+				return err
+			}
+			u.normalized = normalizeWeights(rt, glooPluginConfig.maxTrafficWeight())
+			if err := r.setCanaryWeights(rollout, rt, desiredWeight, glooPluginConfig.maxTrafficWeight()); err != nil {
+				return err
+			}
+			// the weights of released routes are not recorded, so that they are not written back on every reconcile
+			if claim {
+				recordAppliedWeights(rt)
+			}
+			return nil
+		})
+		r.recordWeightChanges(ctx, glooClient, rollout, rt.RouteTable, changes, err)
+		if err != nil {
+			return fmt.Errorf("failed to patch RouteTable %s.%s: %w", rt.RouteTable.Namespace, rt.RouteTable.Name, err)
+		}
+		if !dryRun {
+			u.updated = rt.RouteTable.DeepCopy()
+			updates[i] = u
+		}
+		return nil
+	})
 
-				matchedHttpRoute.Destinations.CanaryOrPreviewDestination.Weight = uint32(desiredWeight)
+	errs := routeTableErrors(results)
+	if len(errs) > 0 && atomicUpdate {
+		return r.rollbackRouteTables(ctx, glooClient, rollout, glooPluginConfig, glooMatchedRouteTables, results, updates, utilerrors.NewAggregate(errs))
+	}
+
+	var updated []*updatedRouteTable
+	for i, result := range results {
+		if result.err == nil && updates[i] != nil {
+			updated = append(updated, updates[i])
+		}
+	}
+	r.completeCanaryUpdates(ctx, glooClient, rollout, updated)
+	return utilerrors.NewAggregate(errs)
+}
+
+// completeCanaryUpdates records the normalization Events and weight metrics of the RouteTables patched by
+// handleCanary. The weights of released routes are not recorded, so that the series dropped by
+// RemoveManagedRoutes stay gone on the reconciles that follow.
+func (r *RpcPlugin) completeCanaryUpdates(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, updated []*updatedRouteTable) {
+	for _, u := range updated {
+		if !u.patched() {
+			continue
+		}
+		rt := u.rt
+		for _, msg := range u.normalized {
+			r.logCtx(ctx).Info(msg)
+			r.recordEvent(ctx, glooClient, rollout, rt.RouteTable, corev1.EventTypeNormal, EventReasonWeightsNormalized, msg)
+		}
+		if u.claimed {
+			for _, w := range rt.routeWeights() {
+				metrics.SetRouteWeights(rollout.Namespace, rollout.Name, rt.RouteTable.Namespace, rt.RouteTable.Name, w.Route, w.Stable, w.Canary)
 			}
 		}
+		r.logCtx(ctx).Debugf("patched route table %s.%s", rt.RouteTable.Namespace, rt.RouteTable.Name)
+	}
+}
+
+// setCanaryWeights sets stable and canary weights on the matched routes (creating canary destinations if required);
+// desiredWeight must be within [0, maxTrafficWeight]. Routes without a canary destination are left as is at a
+// desiredWeight of 0.
+//
+// On routes forwarding to other destinations as well, only the share of the stable and canary destinations is
+// split and the other destinations keep their weights. The plugin keeps the sum of the stable and canary weights
+// constant, so the share is the stable weight from before the rollout began.
+func (r *RpcPlugin) setCanaryWeights(rollout *v1alpha1.Rollout, rt *GlooMatchedRouteTable, desiredWeight, maxTrafficWeight int32) error {
+	if desiredWeight < 0 || desiredWeight > maxTrafficWeight {
+		return fmt.Errorf("desired weight %d is out of range [0, %d]", desiredWeight, maxTrafficWeight)
+	}
 
-		// patch the RT
-		if !r.IsTest {
-			if err := r.Client.RouteTables().PatchRouteTable(ctx, rt.RouteTable, client.MergeFrom(ogRt)); err != nil {
-				return pluginTypes.RpcError{
-					ErrorString: fmt.Sprintf("failed to patch RouteTable: %s", err),
+	for _, matchedHttpRoute := range rt.HttpRoutes {
+		if matchedHttpRoute.Destinations != nil {
+			if matchedHttpRoute.Destinations.CanaryOrPreviewDestination == nil && desiredWeight == 0 {
+				// the stable destination already has all of the traffic; adding a canary destination without weight
+				// would undo collapseAfterPromotion, as Argo Rollouts sets the weight of a fully promoted Rollout to 0
+				// on every reconcile
+				continue
+			}
+			share := uint32(maxTrafficWeight)
+			if matchedHttpRoute.hasOtherDestinations() {
+				share = matchedHttpRoute.Destinations.StableOrActiveDestination.GetWeight() + matchedHttpRoute.Destinations.CanaryOrPreviewDestination.GetWeight()
+				if share == 0 {
+					return fmt.Errorf("cannot split route %s in RouteTable %s.%s: it forwards to other destinations but the stable and canary destinations have no weight", matchedHttpRoute.HttpRoute.GetName(), rt.RouteTable.Namespace, rt.RouteTable.Name)
 				}
 			}
-			r.LogCtx.Debugf("patched route table %s.%s", rt.RouteTable.Namespace, rt.RouteTable.Name)
+			// rounded to the nearest integer weight
+			canaryWeight := uint32((uint64(share)*uint64(desiredWeight) + uint64(maxTrafficWeight)/2) / uint64(maxTrafficWeight))
+
+			matchedHttpRoute.Destinations.StableOrActiveDestination.Weight = share - canaryWeight
+
+			if matchedHttpRoute.Destinations.CanaryOrPreviewDestination == nil {
+				newDest, err := r.newCanaryDest(matchedHttpRoute.Destinations.StableOrActiveDestination, rollout)
+				if err != nil {
+					return err
+				}
+				matchedHttpRoute.Destinations.CanaryOrPreviewDestination = newDest
+				matchedHttpRoute.HttpRoute.GetForwardTo().Destinations = append(matchedHttpRoute.HttpRoute.GetForwardTo().Destinations, matchedHttpRoute.Destinations.CanaryOrPreviewDestination)
+			}
+
+			matchedHttpRoute.Destinations.CanaryOrPreviewDestination.Weight = canaryWeight
 		}
 	}
 
-	return pluginTypes.RpcError{}
+	return nil
+}
+
+// hasOtherDestinations returns true if the route forwards to destinations other than stable and canary
+func (m *GlooMatchedHttpRoutes) hasOtherDestinations() bool {
+	for _, dest := range m.HttpRoute.GetForwardTo().GetDestinations() {
+		if dest != m.Destinations.StableOrActiveDestination && dest != m.Destinations.CanaryOrPreviewDestination {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *RpcPlugin) newCanaryDest(stableDest *solov2.DestinationReference, rollout *v1alpha1.Rollout) (*solov2.DestinationReference, error) {
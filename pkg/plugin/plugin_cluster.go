@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/util"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// getClient returns the client set for the cluster hosting the RouteTables of the given Rollout. Per-Rollout
+// managementCluster config takes precedence over the plugin-wide settings.
+func (r *RpcPlugin) getClient(ctx context.Context, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting) (gloo.NetworkV2ClientSet, error) {
+	if mc := glooPluginConfig.ManagementCluster; mc != nil {
+		if mc.KubeConfigPath != "" && mc.KubeConfigSecretRef != nil {
+			return nil, fmt.Errorf("managementCluster: only one of kubeConfigPath and kubeConfigSecretRef may be specified")
+		}
+		if mc.KubeConfigPath != "" {
+			path, err := r.resolveKubeConfigPath(mc.KubeConfigPath)
+			if err != nil {
+				return nil, err
+			}
+			return r.getClientForFile(path)
+		}
+		if mc.KubeConfigSecretRef != nil {
+			if mc.KubeConfigSecretRef.Name == "" {
+				return nil, fmt.Errorf("managementCluster: kubeConfigSecretRef.name is required")
+			}
+			// per-Rollout Secret refs are limited to the Rollout namespace so that Rollout authors cannot
+			// use kubeconfigs they would not otherwise have access to
+			return r.getClientForSecret(ctx, rollout.Namespace, mc.KubeConfigSecretRef.Name, mc.KubeConfigSecretRef.Key)
+		}
+	}
+
+	if r.Settings != nil && r.Settings.KubeConfigSecret != nil {
+		s := r.Settings.KubeConfigSecret
+		return r.getClientForSecret(ctx, s.Namespace, s.Name, s.Key)
+	}
+
+	if r.Client == nil {
+		return nil, fmt.Errorf("gloo client is not initialized")
+	}
+	return r.Client, nil
+}
+
+// resolveKubeConfigPath resolves a per-Rollout kubeConfigPath within the plugin-wide kubeconfig directory, so that
+// Rollout authors cannot make the plugin read arbitrary files of the Argo Rollouts controller. Relative paths are
+// relative to the directory.
+func (r *RpcPlugin) resolveKubeConfigPath(path string) (string, error) {
+	dir := r.Settings.KubeConfigDir
+	if dir == "" {
+		return "", fmt.Errorf("managementCluster: kubeConfigPath is not allowed unless %s is set", config.EnvKubeConfigDir)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	// symlinks are resolved so that a link within the directory cannot point outside of it
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s %s: %s", config.EnvKubeConfigDir, dir, err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("managementCluster: failed to resolve kubeConfigPath %s: %s", path, err)
+	}
+	rel, err := filepath.Rel(resolvedDir, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("managementCluster: kubeConfigPath %s is not within %s", path, dir)
+	}
+	return resolved, nil
+}
+
+func (r *RpcPlugin) getClientForFile(path string) (gloo.NetworkV2ClientSet, error) {
+	client, err := r.clientSets.GetOrCreate("file:"+path, "", func() (*rest.Config, error) {
+		return util.GetKubeConfigFromFile(path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for kubeconfig %s: %s", path, err)
+	}
+	return client, nil
+}
+
+func (r *RpcPlugin) getClientForSecret(ctx context.Context, namespace, name, key string) (gloo.NetworkV2ClientSet, error) {
+	if r.KubeClient == nil {
+		return nil, fmt.Errorf("kubernetes client is not initialized; cannot read kubeconfig Secret %s.%s", namespace, name)
+	}
+	if key == "" {
+		key = config.DefaultKubeConfigSecretKey
+	}
+
+	secret, err := r.KubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig Secret %s.%s: %s", namespace, name, err)
+	}
+	data, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig Secret %s.%s has no key %s", namespace, name, key)
+	}
+
+	// the Secret resourceVersion invalidates the cached client set when the kubeconfig is rotated
+	client, err := r.clientSets.GetOrCreate(fmt.Sprintf("secret:%s/%s/%s", namespace, name, key), secret.ResourceVersion, func() (*rest.Config, error) {
+		return util.GetKubeConfigFromBytes(data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for kubeconfig Secret %s.%s: %s", namespace, name, err)
+	}
+	return client, nil
+}
+
+// clientOptions returns the options used for every gloo client set created by the plugin
+func (r *RpcPlugin) clientOptions() []gloo.ClientOption {
+	opts := []gloo.ClientOption{
+		gloo.WithRateLimit(r.Settings.ClientQPS, r.Settings.ClientBurst),
+		gloo.WithTimeout(r.Settings.RequestTimeout),
+	}
+	if c := r.Settings.RouteTableCache; c != nil {
+		opts = append(opts, gloo.WithRouteTableCache(c.Namespaces, c.LabelSelector))
+	}
+	return opts
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	solov2 "github.com/solo-io/solo-apis/client-go/common.gloo.solo.io/v2"
+)
+
+// EventReasonRoutesCollapsed is the reason of Events recording a route collapsed back to a single destination
+const EventReasonRoutesCollapsed = "GlooPlatformAPIRoutesCollapsed"
+
+// collapse returns true if the matched routes of a Rollout must be collapsed; only routes of a fully promoted
+// Rollout are collapsed, not those of an aborted one
+func (c *GlooPlatformAPITrafficRouting) collapse(rollout *v1alpha1.Rollout) bool {
+	return c.CollapseAfterPromotion != nil && c.CollapseAfterPromotion.Enabled && isFullyPromoted(rollout)
+}
+
+// isFullyPromoted returns true if the stable ReplicaSet of rollout is its current one (as Argo Rollouts does when
+// deciding to remove managed routes)
+func isFullyPromoted(rollout *v1alpha1.Rollout) bool {
+	return rollout.Status.StableRS == rollout.Status.CurrentPodHash
+}
+
+// collapseRoutes removes the destination without weight from the matched routes of rt whose stable or canary
+// destination has all of their combined weight; usually the canary destination, or the stable destination after
+// the services were swapped. With removeWeight, the weight of the remaining destination is removed as well when it
+// is the only destination of the route. It returns a description of each collapsed route.
+//
+// Routes where both destinations have weight are left as is, as are other destinations.
+func collapseRoutes(rt *GlooMatchedRouteTable, removeWeight bool) []string {
+	var collapsed []string
+	for _, matchedHttpRoute := range rt.HttpRoutes {
+		d := matchedHttpRoute.Destinations
+		if d == nil || d.StableOrActiveDestination == nil || d.CanaryOrPreviewDestination == nil {
+			continue
+		}
+
+		var removed, kept *solov2.DestinationReference
+		switch {
+		case d.CanaryOrPreviewDestination.GetWeight() == 0 && d.StableOrActiveDestination.GetWeight() != 0:
+			removed, kept = d.CanaryOrPreviewDestination, d.StableOrActiveDestination
+			d.CanaryOrPreviewDestination = nil
+		case d.StableOrActiveDestination.GetWeight() == 0 && d.CanaryOrPreviewDestination.GetWeight() != 0:
+			removed, kept = d.StableOrActiveDestination, d.CanaryOrPreviewDestination
+			d.StableOrActiveDestination = nil
+		default:
+			continue
+		}
+
+		fw := matchedHttpRoute.HttpRoute.GetForwardTo()
+		var destinations []*solov2.DestinationReference
+		for _, dest := range fw.GetDestinations() {
+			if dest != removed {
+				destinations = append(destinations, dest)
+			}
+		}
+		fw.Destinations = destinations
+
+		msg := fmt.Sprintf("collapsed route %s in RouteTable %s.%s: removed destination %s without weight", matchedHttpRoute.HttpRoute.GetName(), rt.RouteTable.Namespace, rt.RouteTable.Name, removed.GetRef().GetName())
+		if removeWeight && len(destinations) == 1 {
+			kept.Weight = 0
+			msg = fmt.Sprintf("%s and the weight of destination %s", msg, kept.GetRef().GetName())
+		}
+		collapsed = append(collapsed, msg)
+	}
+	return collapsed
+}
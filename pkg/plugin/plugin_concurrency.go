@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+)
+
+// routeTableResult is the outcome of updating a single matched RouteTable
+type routeTableResult struct {
+	// false if the update was not started because an earlier update failed
+	started bool
+	err     error
+}
+
+// patchConcurrency returns the maximum number of RouteTables updated concurrently
+func (r *RpcPlugin) patchConcurrency() int {
+	if r.Settings != nil && r.Settings.PatchConcurrency > 0 {
+		return r.Settings.PatchConcurrency
+	}
+	return config.DefaultPatchConcurrency
+}
+
+// forEachRouteTable calls update for each of rts, with up to patchConcurrency calls running at once, and waits for
+// them to return. With stopOnError, no further calls are started once a call failed. The results are in the order
+// of rts.
+func (r *RpcPlugin) forEachRouteTable(rts []*GlooMatchedRouteTable, stopOnError bool, update func(i int, rt *GlooMatchedRouteTable) error) []routeTableResult {
+	results := make([]routeTableResult, len(rts))
+	sem := make(chan struct{}, r.patchConcurrency())
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, rt := range rts {
+		sem <- struct{}{}
+		if stopOnError && failed.Load() {
+			<-sem
+			break
+		}
+		results[i].started = true
+		wg.Add(1)
+		go func(i int, rt *GlooMatchedRouteTable) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := update(i, rt); err != nil {
+				results[i].err = err
+				failed.Store(true)
+			}
+		}(i, rt)
+	}
+	wg.Wait()
+	return results
+}
+
+// routeTableErrors returns the errors of results
+func routeTableErrors(results []routeTableResult) []error {
+	var errs []error
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+	return errs
+}
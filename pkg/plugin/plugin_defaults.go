@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keys of the ConfigMap holding plugin config defaults
+const (
+	// defaults for every Rollout
+	ConfigDefaultsKey = "defaults"
+	// prefix of the keys holding the defaults for the Rollouts of a namespace, e.g. namespace.team-a
+	ConfigDefaultsNamespacePrefix = "namespace."
+)
+
+// ConfigDefaults are defaults of the plugin config. The config of each Rollout is deep merged over the defaults of
+// its namespace, which are deep merged over the cluster-wide defaults.
+type ConfigDefaults struct {
+	// defaults for every Rollout
+	Cluster map[string]interface{}
+	// defaults for the Rollouts of a namespace
+	Namespaces map[string]map[string]interface{}
+}
+
+// ParseConfigDefaults parses the data of a plugin config defaults ConfigMap. Each value is a partial plugin config
+// in YAML or JSON; unknown fields are rejected like in the config of a Rollout.
+func ParseConfigDefaults(data map[string]string) (*ConfigDefaults, error) {
+	defaults := &ConfigDefaults{
+		Namespaces: map[string]map[string]interface{}{},
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, k := range keys {
+		namespace := strings.TrimPrefix(k, ConfigDefaultsNamespacePrefix)
+		if k != ConfigDefaultsKey && (namespace == k || namespace == "") {
+			problems = append(problems, fmt.Sprintf("unknown key %q: must be %s or %s<namespace>", k, ConfigDefaultsKey, ConfigDefaultsNamespacePrefix))
+			continue
+		}
+
+		raw, err := yaml.YAMLToJSON([]byte(data[k]))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", k, err))
+			continue
+		}
+		if _, decodeProblems := decodePluginConfig(raw); len(decodeProblems) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", k, strings.Join(decodeProblems, "; ")))
+			continue
+		}
+		values := map[string]interface{}{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", k, err))
+			continue
+		}
+
+		if k == ConfigDefaultsKey {
+			defaults.Cluster = values
+		} else {
+			defaults.Namespaces[namespace] = values
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid plugin config defaults: %s", strings.Join(problems, "; "))
+	}
+	return defaults, nil
+}
+
+// apply deep merges config over the defaults for namespace, returning the merged config
+func (d *ConfigDefaults) apply(namespace string, config json.RawMessage) (json.RawMessage, error) {
+	if d == nil || (len(d.Cluster) == 0 && len(d.Namespaces[namespace]) == 0) {
+		return config, nil
+	}
+
+	var overlay interface{}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &overlay); err != nil {
+			return nil, err
+		}
+	}
+	merged := mergeConfig(mergeConfig(d.Cluster, d.Namespaces[namespace]), overlay)
+	return json.Marshal(merged)
+}
+
+// mergeConfig deep merges overlay over base: objects are merged field by field, other values of overlay replace
+// those of base, and a null in overlay removes the field. base is not modified.
+func mergeConfig(base, overlay interface{}) interface{} {
+	baseObj, ok := base.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	overlayObj, ok := overlay.(map[string]interface{})
+	if !ok {
+		if overlay == nil {
+			return base
+		}
+		return overlay
+	}
+
+	merged := make(map[string]interface{}, len(baseObj)+len(overlayObj))
+	for k, v := range baseObj {
+		merged[k] = v
+	}
+	for k, v := range overlayObj {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = mergeConfig(merged[k], v)
+	}
+	return merged
+}
+
+// loadConfigDefaults reads the plugin config defaults from the ConfigMap configured in the plugin-wide settings
+func (r *RpcPlugin) loadConfigDefaults(ctx context.Context) error {
+	ref := r.Settings.ConfigDefaults
+	if ref == nil {
+		return nil
+	}
+	cm, err := r.KubeClient.CoreV1().ConfigMaps(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get plugin config defaults ConfigMap %s/%s: %s", ref.Namespace, ref.Name, err)
+	}
+	defaults, err := ParseConfigDefaults(cm.Data)
+	if err != nil {
+		return fmt.Errorf("ConfigMap %s/%s: %s", ref.Namespace, ref.Name, err)
+	}
+	r.Defaults = defaults
+	return nil
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AnnotationAppliedWeights records the weights the plugin last applied to each route of a RouteTable, as a JSON object
+// mapping route names to stable and canary weights. Kept on the RouteTable, the record outlives the plugin process.
+const AnnotationAppliedWeights = AnnotationPrefix + "applied-weights"
+
+// appliedWeights maps route names to the weights last applied to them
+type appliedWeights map[string]appliedRouteWeights
+
+type appliedRouteWeights struct {
+	Stable uint32 `json:"stable"`
+	Canary uint32 `json:"canary"`
+}
+
+// getAppliedWeights returns the weights last applied to the routes of rt. An invalid record is treated as no record,
+// as it only serves drift detection and is replaced by the next update.
+func getAppliedWeights(rt *networkv2.RouteTable) appliedWeights {
+	weights := appliedWeights{}
+	if v := rt.Annotations[AnnotationAppliedWeights]; v != "" {
+		if err := json.Unmarshal([]byte(v), &weights); err != nil {
+			return appliedWeights{}
+		}
+	}
+	return weights
+}
+
+// recordAppliedWeights records the current weights of the matched routes of rt as applied; unnamed routes are left
+// out
+func recordAppliedWeights(rt *GlooMatchedRouteTable) {
+	weights := getAppliedWeights(rt.RouteTable)
+	for _, w := range rt.routeWeights() {
+		if w.Route != "" {
+			weights[w.Route] = appliedRouteWeights{Stable: w.Stable, Canary: w.Canary}
+		}
+	}
+	setMapAnnotation(rt.RouteTable, AnnotationAppliedWeights, weights)
+}
+
+// forgetAppliedWeights removes the record of the weights applied to the given routes of rt
+func forgetAppliedWeights(rt *GlooMatchedRouteTable, routes []string) {
+	weights := getAppliedWeights(rt.RouteTable)
+	for _, route := range routes {
+		delete(weights, route)
+	}
+	setMapAnnotation(rt.RouteTable, AnnotationAppliedWeights, weights)
+}
+
+// weightDrift describes a route whose live weights differ from the weights last applied by the plugin
+type weightDrift struct {
+	Route    string
+	Expected routeWeights
+	Actual   routeWeights
+}
+
+func (d weightDrift) String() string {
+	return fmt.Sprintf("route %s: expected stable %d, canary %d; found stable %d, canary %d", d.Route, d.Expected.Stable, d.Expected.Canary, d.Actual.Stable, d.Actual.Canary)
+}
+
+// detectDrift compares the live weights of the matched routes of rt with the weights last applied to them. Routes
+// the plugin has not applied weights to yet, and unnamed routes, are skipped.
+func (r *RpcPlugin) detectDrift(rt *GlooMatchedRouteTable) []weightDrift {
+	applied := getAppliedWeights(rt.RouteTable)
+	var drifts []weightDrift
+	for _, live := range rt.routeWeights() {
+		w, ok := applied[live.Route]
+		if !ok || live.Route == "" {
+			continue
+		}
+		expected := routeWeights{Route: live.Route, Stable: w.Stable, Canary: w.Canary}
+		if expected == live {
+			continue
+		}
+		drifts = append(drifts, weightDrift{
+			Route:    live.Route,
+			Expected: expected,
+			Actual:   live,
+		})
+	}
+	return drifts
+}
+
+// handleDrift detects drift in the matched RouteTables and takes the drift action, recording an Event for each
+// drifted RouteTable. It returns the RouteTables SetWeight may write.
+func (r *RpcPlugin) handleDrift(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting, matchedRts []*GlooMatchedRouteTable) ([]*GlooMatchedRouteTable, error) {
+	action := r.driftAction(glooPluginConfig)
+
+	var writable []*GlooMatchedRouteTable
+	var failed []string
+	for _, rt := range matchedRts {
+		drifts := r.detectDrift(rt)
+		if len(drifts) == 0 {
+			writable = append(writable, rt)
+			continue
+		}
+
+		var described []string
+		for _, d := range drifts {
+			described = append(described, d.String())
+		}
+		msg := fmt.Sprintf("weights of RouteTable %s.%s drifted (%s); drift action is %s", rt.RouteTable.Namespace, rt.RouteTable.Name, strings.Join(described, "; "), action)
+		r.logCtx(ctx).Warn(msg)
+		r.recordEvent(ctx, glooClient, rollout, rt.RouteTable, corev1.EventTypeWarning, EventReasonWeightDrift, msg)
+
+		switch action {
+		case config.DriftActionReapply:
+			writable = append(writable, rt)
+		case config.DriftActionFail:
+			failed = append(failed, fmt.Sprintf("RouteTable %s.%s (%s)", rt.RouteTable.Namespace, rt.RouteTable.Name, strings.Join(described, "; ")))
+		}
+	}
+
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("weights drifted from the weights last applied: %s", strings.Join(failed, ", "))
+	}
+	return writable, nil
+}
+
+// driftAction returns the drift action of a Rollout, defaulting to the plugin-wide setting
+func (r *RpcPlugin) driftAction(glooPluginConfig *GlooPlatformAPITrafficRouting) string {
+	if glooPluginConfig.DriftAction != "" {
+		return glooPluginConfig.DriftAction
+	}
+	if r.Settings != nil && r.Settings.DriftAction != "" {
+		return r.Settings.DriftAction
+	}
+	return config.DriftActionReapply
+}
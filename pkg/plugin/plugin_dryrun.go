@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventReasonDryRun is the reason of Events recording a RouteTable patch that was not sent because of dry run
+	EventReasonDryRun = "GlooPlatformAPIDryRun"
+
+	// patches longer than this are truncated in dry run Events; the log has the full patch
+	maxDryRunEventPatch = 1024
+)
+
+// dryRun returns true if RouteTables must not be written for a Rollout. The Rollout setting takes precedence over
+// the plugin-wide setting.
+func (r *RpcPlugin) dryRun(glooPluginConfig *GlooPlatformAPITrafficRouting) bool {
+	if glooPluginConfig.DryRun != nil {
+		return *glooPluginConfig.DryRun
+	}
+	return r.Settings != nil && r.Settings.DryRun
+}
+
+// dryRunPatches records the last dry run patch recorded in an Event per Rollout and RouteTable, so that a patch
+// computed again on every reconcile is only recorded once. Entries are kept for the life of the plugin process.
+type dryRunPatches struct {
+	mu sync.Mutex
+	// Rollout namespace/name/RouteTable namespace/name -> patch
+	patches map[string]string
+}
+
+// changed records patch for the Rollout and RouteTable of key and returns true if it differs from the last one
+func (d *dryRunPatches) changed(key string, patch []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.patches == nil {
+		d.patches = map[string]string{}
+	}
+	if d.patches[key] == string(patch) {
+		return false
+	}
+	d.patches[key] = string(patch)
+	return true
+}
+
+// recordDryRun logs and records an Event for the patch of rt that would have been sent. A patch already recorded
+// for the Rollout and RouteTable is only logged at debug level.
+func (r *RpcPlugin) recordDryRun(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, rt *networkv2.RouteTable, changes []routeWeightChange, patch []byte) {
+	var described []string
+	for _, c := range changes {
+		described = append(described, c.String())
+	}
+	summary := fmt.Sprintf("dry run: would update RouteTable %s.%s", rt.Namespace, rt.Name)
+	if len(described) > 0 {
+		summary = fmt.Sprintf("%s (%s)", summary, strings.Join(described, "; "))
+	}
+	if !r.dryRunPatches.changed(fmt.Sprintf("%s/%s/%s", rolloutKey(rollout), rt.Namespace, rt.Name), patch) {
+		r.logCtx(ctx).Debugf("%s (already recorded): %s", summary, patch)
+		return
+	}
+	r.logCtx(ctx).Infof("%s: %s", summary, patch)
+
+	eventPatch := string(patch)
+	if len(eventPatch) > maxDryRunEventPatch {
+		eventPatch = eventPatch[:maxDryRunEventPatch] + "..."
+	}
+	r.recordEvent(ctx, glooClient, rollout, rt, corev1.EventTypeNormal, EventReasonDryRun, fmt.Sprintf("%s: %s", summary, eventPatch))
+}
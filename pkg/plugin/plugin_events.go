@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventReasonWeightUpdated is the reason of Events recording a weight change applied to a RouteTable
+	EventReasonWeightUpdated = "GlooPlatformAPIWeightUpdated"
+	// EventReasonWeightDrift is the reason of Events recording weights that no longer match the weights last applied
+	EventReasonWeightDrift = "GlooPlatformAPIWeightDrift"
+)
+
+// routeWeights are the stable and canary weights of a matched route
+type routeWeights struct {
+	Route  string
+	Stable uint32
+	Canary uint32
+}
+
+// routeWeightChange describes the weights of a matched route before and after an update
+type routeWeightChange struct {
+	Route     string
+	OldStable uint32
+	OldCanary uint32
+	NewStable uint32
+	NewCanary uint32
+}
+
+func (c routeWeightChange) String() string {
+	return fmt.Sprintf("route %s: stable %d -> %d, canary %d -> %d", c.Route, c.OldStable, c.NewStable, c.OldCanary, c.NewCanary)
+}
+
+// routeWeights returns the weights of the matched http routes, in the order of HttpRoutes
+func (g *GlooMatchedRouteTable) routeWeights() []routeWeights {
+	var weights []routeWeights
+	for _, matchedHttpRoute := range g.HttpRoutes {
+		w := routeWeights{
+			Route: matchedHttpRoute.HttpRoute.GetName(),
+		}
+		if d := matchedHttpRoute.Destinations; d != nil {
+			w.Stable = d.StableOrActiveDestination.GetWeight()
+			w.Canary = d.CanaryOrPreviewDestination.GetWeight()
+		}
+		weights = append(weights, w)
+	}
+	return weights
+}
+
+// weightChanges pairs the route weights from before and after an update, dropping routes whose weights did not change
+func weightChanges(before, after []routeWeights) []routeWeightChange {
+	var changes []routeWeightChange
+	for i := 0; i < len(before) && i < len(after); i++ {
+		if before[i] == after[i] {
+			continue
+		}
+		changes = append(changes, routeWeightChange{
+			Route:     after[i].Route,
+			OldStable: before[i].Stable,
+			OldCanary: before[i].Canary,
+			NewStable: after[i].Stable,
+			NewCanary: after[i].Canary,
+		})
+	}
+	return changes
+}
+
+// recordWeightChanges records an Event on the Rollout (and, if enabled, on the RouteTable) for each changed route
+// of rt, or a warning Event if updating the RouteTable failed
+func (r *RpcPlugin) recordWeightChanges(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, rt *networkv2.RouteTable, changes []routeWeightChange, updateErr error) {
+	if updateErr != nil {
+		msg := fmt.Sprintf("failed to update RouteTable %s.%s: %s", rt.Namespace, rt.Name, updateErr)
+		if len(changes) > 0 {
+			var attempted []string
+			for _, c := range changes {
+				attempted = append(attempted, c.String())
+			}
+			msg = fmt.Sprintf("failed to update RouteTable %s.%s (%s): %s", rt.Namespace, rt.Name, strings.Join(attempted, "; "), updateErr)
+		}
+		r.recordEvent(ctx, glooClient, rollout, rt, corev1.EventTypeWarning, GlooPlatformAPIUpdateError, msg)
+		return
+	}
+
+	for _, c := range changes {
+		msg := fmt.Sprintf("updated RouteTable %s.%s %s", rt.Namespace, rt.Name, c)
+		r.recordEvent(ctx, glooClient, rollout, rt, corev1.EventTypeNormal, EventReasonWeightUpdated, msg)
+	}
+}
+
+func (r *RpcPlugin) recordEvent(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, rt *networkv2.RouteTable, eventType, reason, msg string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(rollout, eventType, reason, msg)
+	}
+	if r.Settings != nil && r.Settings.RouteTableEvents && rt != nil {
+		rtMsg := fmt.Sprintf("Rollout %s.%s: %s", rollout.Namespace, rollout.Name, msg)
+		if err := glooClient.RouteTables().RecordRouteTableEvent(ctx, rt, eventType, reason, rtMsg); err != nil {
+			r.logCtx(ctx).Warnf("failed to record event on route table %s.%s: %s", rt.Namespace, rt.Name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/argoproj/argo-rollouts/utils/annotations"
+	"github.com/sirupsen/logrus"
+)
+
+type logCtxKey struct{}
+
+// rolloutLogCtx returns the logger for a call made for rollout, carrying the fields that identify the Rollout
+// and the step it is on
+func (r *RpcPlugin) rolloutLogCtx(rollout *v1alpha1.Rollout) *logrus.Entry {
+	fields := logrus.Fields{
+		"namespace": rollout.Namespace,
+		"rollout":   rollout.Name,
+	}
+	if revision, ok := rollout.Annotations[annotations.RevisionAnnotation]; ok {
+		fields["revision"] = revision
+	}
+	if rollout.Status.CurrentStepIndex != nil {
+		fields["stepIndex"] = *rollout.Status.CurrentStepIndex
+	}
+	return r.baseLogCtx().WithFields(fields)
+}
+
+// logCtx returns the logger of the call ctx belongs to
+func (r *RpcPlugin) logCtx(ctx context.Context) *logrus.Entry {
+	if logCtx, ok := ctx.Value(logCtxKey{}).(*logrus.Entry); ok {
+		return logCtx
+	}
+	return r.baseLogCtx()
+}
+
+func (r *RpcPlugin) baseLogCtx() *logrus.Entry {
+	if r.LogCtx == nil {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	return r.LogCtx
+}
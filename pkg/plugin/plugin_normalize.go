@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventReasonWeightsNormalized is the reason of Events recording the explicit weights given to unweighted destinations
+const EventReasonWeightsNormalized = "GlooPlatformAPIWeightsNormalized"
+
+// normalizeWeights gives explicit weights to the destinations of matched routes forwarding to more than one
+// destination, none of which has a weight. Gloo splits the traffic of such routes evenly, so each destination gets
+// maxTrafficWeight, which keeps the split even and makes the stable share maxTrafficWeight. It returns a
+// description of the effective weights of each normalized route.
+//
+// Routes with a single unweighted destination need no normalization; the destination gets all of the traffic and
+// setCanaryWeights splits maxTrafficWeight.
+func normalizeWeights(rt *GlooMatchedRouteTable, maxTrafficWeight int32) []string {
+	var normalized []string
+	for _, matchedHttpRoute := range rt.HttpRoutes {
+		destinations := matchedHttpRoute.HttpRoute.GetForwardTo().GetDestinations()
+		if len(destinations) < 2 {
+			continue
+		}
+		unweighted := true
+		for _, dest := range destinations {
+			if dest.GetWeight() != 0 {
+				unweighted = false
+				break
+			}
+		}
+		if !unweighted {
+			continue
+		}
+
+		var effective []string
+		for i, dest := range destinations {
+			name := fmt.Sprintf("destination %d", i)
+			if ref := dest.GetRef(); ref != nil {
+				name = ref.Name
+			}
+			effective = append(effective, fmt.Sprintf("%s %.4g%%", name, 100/float64(len(destinations))))
+			dest.Weight = uint32(maxTrafficWeight)
+		}
+		normalized = append(normalized, fmt.Sprintf("route %s in RouteTable %s.%s had no explicit weights (effective weights: %s); set the weight of each destination to %d",
+			matchedHttpRoute.HttpRoute.GetName(), rt.RouteTable.Namespace, rt.RouteTable.Name, strings.Join(effective, ", "), maxTrafficWeight))
+	}
+	return normalized
+}
@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AnnotationRouteOwners records which Rollout manages each route of a RouteTable, as a JSON object mapping route
+// names to owner keys (see ownerKey)
+const AnnotationRouteOwners = AnnotationPrefix + "route-owners"
+
+// routeOwners maps route names to the owner key of the Rollout managing them
+type routeOwners map[string]string
+
+func getRouteOwners(rt *networkv2.RouteTable) (routeOwners, error) {
+	owners := routeOwners{}
+	if v := rt.Annotations[AnnotationRouteOwners]; v != "" {
+		if err := json.Unmarshal([]byte(v), &owners); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on RouteTable %s.%s: %s", AnnotationRouteOwners, rt.Namespace, rt.Name, err)
+		}
+	}
+	return owners, nil
+}
+
+func rolloutKey(rollout *v1alpha1.Rollout) string {
+	return fmt.Sprintf("%s/%s", rollout.Namespace, rollout.Name)
+}
+
+// ownerKey identifies rollout in route owners: its namespace/name, prefixed with the cluster name if set so that
+// Rollouts with the same namespace and name in different clusters sharing RouteTables are told apart
+func (r *RpcPlugin) ownerKey(rollout *v1alpha1.Rollout) string {
+	if r.Settings == nil || r.Settings.ClusterName == "" {
+		return rolloutKey(rollout)
+	}
+	return fmt.Sprintf("%s/%s", r.Settings.ClusterName, rolloutKey(rollout))
+}
+
+// claimsRoutes returns true if rollout claims the routes it sets to desiredWeight: while it has canary weight or
+// an update is in progress. Argo Rollouts sets the weight of a fully promoted Rollout to 0 on every reconcile,
+// right after removing its managed routes, which must not claim the routes again.
+func claimsRoutes(rollout *v1alpha1.Rollout, desiredWeight int32) bool {
+	return desiredWeight > 0 || !isFullyPromoted(rollout)
+}
+
+// checkRouteOwners returns an error if a matched route of rt is claimed by another Rollout, unless that Rollout no
+// longer exists. With claim, the matched routes are claimed for rollout, taking over the claims of Rollouts that no
+// longer exist.
+func (r *RpcPlugin) checkRouteOwners(ctx context.Context, rollout *v1alpha1.Rollout, rt *GlooMatchedRouteTable, claim bool) error {
+	owners, err := getRouteOwners(rt.RouteTable)
+	if err != nil {
+		return err
+	}
+
+	owner := r.ownerKey(rollout)
+	for _, matchedHttpRoute := range rt.HttpRoutes {
+		name := matchedHttpRoute.HttpRoute.GetName()
+		if name == "" {
+			r.logCtx(ctx).Debugf("not claiming unnamed route in route table %s.%s", rt.RouteTable.Namespace, rt.RouteTable.Name)
+			continue
+		}
+		current, ok := owners[name]
+		if ok && current != owner {
+			exists, err := r.rolloutExists(ctx, current)
+			if err != nil {
+				return fmt.Errorf("failed to get Rollout %s managing route %s in RouteTable %s.%s: %s", current, name, rt.RouteTable.Namespace, rt.RouteTable.Name, err)
+			}
+			if exists {
+				return fmt.Errorf("route %s in RouteTable %s.%s is managed by Rollout %s", name, rt.RouteTable.Namespace, rt.RouteTable.Name, current)
+			}
+			if !claim {
+				continue
+			}
+			r.logCtx(ctx).Infof("taking over route %s in route table %s.%s from deleted Rollout %s", name, rt.RouteTable.Namespace, rt.RouteTable.Name, current)
+		}
+		if claim {
+			owners[name] = owner
+		}
+	}
+	setMapAnnotation(rt.RouteTable, AnnotationRouteOwners, owners)
+	return nil
+}
+
+// releaseRoutes releases every route of rt claimed by rollout, along with the record of the weights applied to them
+func (r *RpcPlugin) releaseRoutes(rollout *v1alpha1.Rollout, rt *GlooMatchedRouteTable) error {
+	owners, err := getRouteOwners(rt.RouteTable)
+	if err != nil {
+		return err
+	}
+	owner := r.ownerKey(rollout)
+	var released []string
+	for name, current := range owners {
+		if current == owner {
+			delete(owners, name)
+			released = append(released, name)
+		}
+	}
+	forgetAppliedWeights(rt, released)
+	setMapAnnotation(rt.RouteTable, AnnotationRouteOwners, owners)
+	return nil
+}
+
+// rolloutExists returns true if the Rollout with the given owner key exists. Without a Rollouts client, or if the
+// Rollout runs in another cluster, the Rollout is assumed to exist. Keys without a cluster name are assumed to be
+// of this cluster.
+func (r *RpcPlugin) rolloutExists(ctx context.Context, key string) (bool, error) {
+	if r.RolloutsClient == nil {
+		return true, nil
+	}
+	if parts := strings.SplitN(key, "/", 3); len(parts) == 3 {
+		if r.Settings == nil || parts[0] != r.Settings.ClusterName {
+			return true, nil
+		}
+		key = parts[1] + "/" + parts[2]
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = r.RolloutsClient.ArgoprojV1alpha1().Rollouts(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,223 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/metrics"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/tracing"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// patchRetryBackoff bounds the retries of a RouteTable patch that conflicts with a concurrent update
+var patchRetryBackoff = retry.DefaultBackoff
+
+// updateRouteTable applies mutate to the matched RouteTable and writes it with a minimal JSON patch. The patch
+// tests the identity and current value of everything it changes, and the resourceVersion if it adds anything, so
+// a concurrent update of those fields (or a reorder of the routes or destinations) fails the patch instead of
+// being overwritten; on failure the RouteTable is read again, its routes are matched again and mutate is
+// re-applied. Nothing is sent if mutate did not change the spec or the state annotations (route owners and applied
+// weights); otherwise the patch also sets the audit annotations.
+//
+// With serverSideApply, the routes are applied instead and conflicts are field ownership conflicts that a
+// retry cannot resolve, so they are returned as is; see applyRouteTable.
+//
+// The returned changes describe the weights of the matched routes before and after mutate, including when
+// writing the RouteTable failed. In dry run mode the patch is recorded instead of sent and no changes are returned.
+func (r *RpcPlugin) updateRouteTable(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting, rt *GlooMatchedRouteTable, mutate func(rt *GlooMatchedRouteTable) error) (changes []routeWeightChange, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "UpdateRouteTable", trace.WithAttributes(
+		attribute.String("routetable.namespace", rt.RouteTable.Namespace),
+		attribute.String("routetable.name", rt.RouteTable.Name),
+	))
+	defer func() {
+		span.SetAttributes(routeWeightsAttribute(rt))
+		tracing.End(span, err)
+	}()
+
+	if glooPluginConfig.ServerSideApply {
+		var ogRt *networkv2.RouteTable
+		ogRt, changes, err = mutateRouteTable(rt, mutate)
+		if err != nil {
+			return changes, err
+		}
+		patch, changed, err := buildRouteTablePatch(rollout, ogRt, rt, r.dryRun(glooPluginConfig))
+		if err != nil || !changed {
+			return changes, err
+		}
+		if r.dryRun(glooPluginConfig) {
+			r.recordDryRun(ctx, glooClient, rollout, rt.RouteTable, changes, patch)
+			return nil, nil
+		}
+		start := time.Now()
+		err = r.applyRouteTable(ctx, glooClient, glooPluginConfig, rt)
+		metrics.ObservePatch(start, err)
+		return changes, err
+	}
+
+	attempt := 0
+	err = retry.OnError(patchRetryBackoff, isPatchConflict, func() error {
+		if attempt > 0 {
+			metrics.IncPatchRetries()
+			r.logCtx(ctx).Debugf("retrying patch of route table %s.%s after conflict (attempt %d)", rt.RouteTable.Namespace, rt.RouteTable.Name, attempt+1)
+			if err := r.rematchRouteTable(ctx, glooClient, rollout, glooPluginConfig, rt); err != nil {
+				return err
+			}
+		}
+		attempt++
+
+		ogRt, c, err := mutateRouteTable(rt, mutate)
+		changes = c
+		if err != nil {
+			return err
+		}
+
+		patch, changed, err := buildRouteTablePatch(rollout, ogRt, rt, r.dryRun(glooPluginConfig))
+		if err != nil {
+			return err
+		}
+		if !changed {
+			r.logCtx(ctx).Debugf("route table %s.%s is up to date; skipping patch", rt.RouteTable.Namespace, rt.RouteTable.Name)
+			return nil
+		}
+		if r.dryRun(glooPluginConfig) {
+			r.recordDryRun(ctx, glooClient, rollout, rt.RouteTable, changes, patch)
+			changes = nil
+			return nil
+		}
+		r.logCtx(ctx).Debugf("patching route table %s.%s: %s", rt.RouteTable.Namespace, rt.RouteTable.Name, patch)
+		start := time.Now()
+		err = glooClient.RouteTables().PatchRouteTable(ctx, rt.RouteTable, client.RawPatch(types.JSONPatchType, patch))
+		metrics.ObservePatch(start, err)
+		if err != nil && isPatchConflict(err) {
+			metrics.IncPatchConflicts()
+		}
+		return err
+	})
+	return changes, err
+}
+
+// mutateRouteTable applies mutate to rt, returning a copy of the RouteTable from before mutate (to use for patch
+// generation) and the weight changes of the matched routes
+func mutateRouteTable(rt *GlooMatchedRouteTable, mutate func(rt *GlooMatchedRouteTable) error) (*networkv2.RouteTable, []routeWeightChange, error) {
+	ogRt := &networkv2.RouteTable{}
+	rt.RouteTable.DeepCopyInto(ogRt)
+
+	before := rt.routeWeights()
+	err := mutate(rt)
+	return ogRt, weightChanges(before, rt.routeWeights()), err
+}
+
+// buildRouteTablePatch builds the JSON patch from ogRt to rt. If the spec or the state annotations changed, the audit
+// annotations are set on rt and included in the patch; the returned bool is false (and rt is left as is) if
+// neither changed. In dry run, the update time is left out so that the patch of a RouteTable only changes with
+// the RouteTable.
+func buildRouteTablePatch(rollout *v1alpha1.Rollout, ogRt *networkv2.RouteTable, rt *GlooMatchedRouteTable, dryRun bool) ([]byte, bool, error) {
+	_, changed, err := gloo.BuildRouteTablePatch(ogRt, rt.RouteTable, gloo.WithSpec(), gloo.AsJSONPatch())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build patch: %s", err)
+	}
+	if !changed && !stateAnnotationsChanged(ogRt, rt.RouteTable) {
+		return nil, false, nil
+	}
+
+	setAuditAnnotations(rollout, rt)
+	if dryRun {
+		if updatedAt, ok := ogRt.Annotations[AnnotationUpdatedAt]; ok {
+			rt.RouteTable.Annotations[AnnotationUpdatedAt] = updatedAt
+		} else {
+			delete(rt.RouteTable.Annotations, AnnotationUpdatedAt)
+		}
+	}
+	patch, changed, err := gloo.BuildRouteTablePatch(ogRt, rt.RouteTable, gloo.WithSpec(), gloo.WithAnnotations(), gloo.AsJSONPatch())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to build patch: %s", err)
+	}
+	return patch, changed, nil
+}
+
+func stateAnnotationsChanged(ogRt, rt *networkv2.RouteTable) bool {
+	for _, key := range stateAnnotations {
+		if ogRt.Annotations[key] != rt.Annotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// isPatchConflict returns true if a patch failed because the RouteTable changed after it was read: either a
+// resourceVersion conflict or a JSON patch that no longer applies, such as a failed test operation. The API server
+// reports the latter as an invalid request without causes; validation errors of the patched RouteTable name the
+// invalid fields as causes, and retrying them would fail the same way.
+func isPatchConflict(err error) bool {
+	if apierrors.IsConflict(err) {
+		return true
+	}
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	details := status.Status().Details
+	return details == nil || len(details.Causes) == 0
+}
+
+// rematchRouteTable replaces rt with a fresh read of its RouteTable and matches its routes again
+func (r *RpcPlugin) rematchRouteTable(ctx context.Context, glooClient gloo.NetworkV2ClientSet, rollout *v1alpha1.Rollout, glooPluginConfig *GlooPlatformAPITrafficRouting, rt *GlooMatchedRouteTable) error {
+	fresh, err := glooClient.RouteTables().GetRouteTable(ctx, rt.RouteTable.Name, rt.RouteTable.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get RouteTable %s.%s: %s", rt.RouteTable.Namespace, rt.RouteTable.Name, err)
+	}
+
+	matchedRt := &GlooMatchedRouteTable{
+		RouteTable: fresh,
+	}
+	if err := matchedRt.matchRoutes(r.logCtx(ctx), rollout, glooPluginConfig); err != nil {
+		return err
+	}
+	*rt = *matchedRt
+	return nil
+}
+
+// applyRouteTable server-side applies the routes and plugin annotations of rt, so that the plugin does not take
+// ownership of the rest of the RouteTable. spec.http is an atomic list, so the plugin owns the list as a whole
+// rather than the weights within it, and any change to it by another field manager conflicts with the plugin.
+//
+// With forceOwnership, the plugin takes spec.http over from the other field managers instead. The routes applied
+// are those read, so the apply carries the resourceVersion read, and a RouteTable changed since fails the apply
+// instead of being overwritten.
+func (r *RpcPlugin) applyRouteTable(ctx context.Context, glooClient gloo.NetworkV2ClientSet, glooPluginConfig *GlooPlatformAPITrafficRouting, rt *GlooMatchedRouteTable) error {
+	applyRt := &networkv2.RouteTable{}
+	applyRt.Name = rt.RouteTable.Name
+	applyRt.Namespace = rt.RouteTable.Namespace
+	applyRt.Annotations = getPluginAnnotations(rt)
+	applyRt.Spec.Http = rt.RouteTable.Spec.Http
+
+	var opts []client.PatchOption
+	if glooPluginConfig.ForceOwnership {
+		applyRt.ResourceVersion = rt.RouteTable.ResourceVersion
+		opts = append(opts, client.ForceOwnership)
+	}
+	if err := glooClient.RouteTables().ApplyRouteTable(ctx, applyRt, opts...); err != nil {
+		if apierrors.IsConflict(err) {
+			if glooPluginConfig.ForceOwnership {
+				return fmt.Errorf("RouteTable changed since it was read: %s", err)
+			}
+			return fmt.Errorf("server-side apply as %s conflicts with another field manager: %s", gloo.FieldManager, err)
+		}
+		return err
+	}
+	rt.RouteTable.ResourceVersion = applyRt.ResourceVersion
+	return nil
+}
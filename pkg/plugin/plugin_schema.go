@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigJSONSchema returns the JSON Schema of the plugin config, generated from GlooPlatformAPITrafficRouting.
+//
+// Fields are described by their Go types; a jsonschema struct tag adds constraints to a field as a comma separated
+// list of required, enum=a|b|c and minimum=n.
+func ConfigJSONSchema() ([]byte, error) {
+	schema, err := typeSchema(reflect.TypeOf(GlooPlatformAPITrafficRouting{}))
+	if err != nil {
+		return nil, err
+	}
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = fmt.Sprintf("%s traffic router plugin config", PluginName)
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := jsonName(f)
+			property, err := typeSchema(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", t.Name(), f.Name, err)
+			}
+			isRequired, err := applySchemaTag(property, f.Tag.Get("jsonschema"))
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %s", t.Name(), f.Name, err)
+			}
+			if isRequired {
+				required = append(required, name)
+			}
+			properties[name] = property
+		}
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// applySchemaTag adds the constraints of a jsonschema struct tag to schema, returning true if the field is required
+func applySchemaTag(schema map[string]interface{}, tag string) (bool, error) {
+	required := false
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "":
+		case "required":
+			required = true
+		case "enum":
+			schema["enum"] = strings.Split(value, "|")
+		case "minimum":
+			minimum, err := strconv.Atoi(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid minimum %q: %s", value, err)
+			}
+			schema["minimum"] = minimum
+		default:
+			return false, fmt.Errorf("unknown jsonschema option %q", key)
+		}
+	}
+	return required, nil
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+)
+
+// selectorTemplateData is the data selector templates are rendered with, e.g. {{ .Rollout.Name }}
+type selectorTemplateData struct {
+	Rollout *v1alpha1.Rollout
+}
+
+// renderSelectors renders the templates in the names, namespaces and label values of the selectors against rollout,
+// in place. It returns a problem for each template that fails to render or renders an empty value, and the paths of
+// those fields, which are left out of validate as their values are not the ones that would be used.
+func (c *GlooPlatformAPITrafficRouting) renderSelectors(rollout *v1alpha1.Rollout) ([]string, map[string]bool) {
+	data := selectorTemplateData{Rollout: rollout}
+	var problems []string
+	unrendered := map[string]bool{}
+	render := func(path string, value string) string {
+		if !strings.Contains(value, "{{") {
+			return value
+		}
+		tmpl, err := template.New(path).Option("missingkey=error").Parse(value)
+		if err != nil {
+			problems = append(problems, err.Error())
+			unrendered[path] = true
+			return value
+		}
+		var rendered strings.Builder
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			problems = append(problems, err.Error())
+			unrendered[path] = true
+			return value
+		}
+		if rendered.Len() == 0 {
+			problems = append(problems, fmt.Sprintf("%s: template %q rendered an empty value", path, value))
+			unrendered[path] = true
+		}
+		return rendered.String()
+	}
+	renderLabels := func(path string, labels map[string]string) {
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			labels[k] = render(path+"."+k, labels[k])
+		}
+	}
+
+	if s := c.RouteTableSelector; s != nil {
+		s.Name = render("routeTableSelector.name", s.Name)
+		s.Namespace = render("routeTableSelector.namespace", s.Namespace)
+		renderLabels("routeTableSelector.labels", s.Labels)
+	}
+	if s := c.RouteSelector; s != nil {
+		s.Name = render("routeSelector.name", s.Name)
+		renderLabels("routeSelector.labels", s.Labels)
+	}
+	return problems, unrendered
+}
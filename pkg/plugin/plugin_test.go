@@ -4,22 +4,50 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/PaesslerAG/gval"
 	"github.com/PaesslerAG/jsonpath"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/gloo"
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/metrics"
 	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/mocks"
 	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	rolloutsfake "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned/fake"
 	rolloutsPlugin "github.com/argoproj/argo-rollouts/rollout/trafficrouting/plugin/rpc"
+	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
 	"github.com/ghodss/yaml"
+	solov2 "github.com/solo-io/solo-apis/client-go/common.gloo.solo.io/v2"
 	networkv2 "github.com/solo-io/solo-apis/client-go/networking.gloo.solo.io/v2"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/typed"
 
 	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 
 	goPlugin "github.com/hashicorp/go-plugin"
 )
@@ -58,79 +86,19 @@ func (tc *TestCase) Validate() error {
 }
 
 func (tc *TestCase) Test(t *testing.T) error {
-	logCtx := log.WithFields(log.Fields{"plugin": "trafficrouter"})
 	log.SetLevel(log.DebugLevel)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	mockClient := mocks.NewGlooMockClient([]*networkv2.RouteTable{tc.RouteTable})
+	rpcPluginImp := newTestPlugin(tc.Rollout, tc.RouteTable)
 
-	rpcPluginImp := &RpcPlugin{
-		LogCtx: logCtx,
-		IsTest: true,
-		Client: mockClient,
-	}
-
-	var pluginMap = map[string]goPlugin.Plugin{
-		"RpcTrafficRouterPlugin": &rolloutsPlugin.RpcTrafficRouterPlugin{Impl: rpcPluginImp},
-	}
-
-	ch := make(chan *goPlugin.ReattachConfig, 1)
-	closeCh := make(chan struct{})
-	go goPlugin.Serve(&goPlugin.ServeConfig{
-		HandshakeConfig: testHandshake,
-		Plugins:         pluginMap,
-		Test: &goPlugin.ServeTestConfig{
-			Context:          ctx,
-			ReattachConfigCh: ch,
-			CloseCh:          closeCh,
-		},
-	})
-
-	var config *goPlugin.ReattachConfig
-	select {
-	case config = <-ch:
-	case <-time.After(2000 * time.Millisecond):
-		return fmt.Errorf("should've received reattach")
-	}
-	if config == nil {
-		return fmt.Errorf("config should not be nil")
-	}
-
-	// Connect!
-	c := goPlugin.NewClient(&goPlugin.ClientConfig{
-		Cmd:             nil,
-		HandshakeConfig: testHandshake,
-		Plugins:         pluginMap,
-		Reattach:        config,
-	})
-	client, err := c.Client()
-	if err != nil {
-		return fmt.Errorf("err: %s", err)
-	}
-
-	// Pinging should work
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("should not err: %s", err)
-	}
-
-	// Kill which should do nothing
-	c.Kill()
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("should not err: %s", err)
-	}
-
-	// Request the plugin
-	raw, err := client.Dispense("RpcTrafficRouterPlugin")
+	pluginInstance, closeCh, err := serveTestPlugin(ctx, rpcPluginImp)
 	if err != nil {
 		return err
 	}
-
-	pluginInstance := raw.(*rolloutsPlugin.TrafficRouterPluginRPC)
-	err = pluginInstance.InitPlugin()
-	if err.Error() != "" {
-		return err
+	if rpcErr := pluginInstance.InitPlugin(); rpcErr.HasError() {
+		return rpcErr
 	}
 
 	t.Run(tc.fileName, func(t *testing.T) {
@@ -141,7 +109,7 @@ func (tc *TestCase) Test(t *testing.T) error {
 						rpcError := pluginInstance.SetWeight(tc.Rollout, *step.SetWeight, []v1alpha1.WeightDestination{})
 						assert.Empty(t, rpcError.ErrorString)
 
-						jsonRtBytes, err := json.Marshal(tc.RouteTable)
+						jsonRtBytes, err := json.Marshal(getRouteTable(t, rpcPluginImp, tc.RouteTable.Name, tc.RouteTable.Namespace))
 						assert.Empty(t, err, "failed to marshal test case RouteTable")
 
 						// raw json is used for jsonpath expressions in test case files
@@ -237,6 +205,67 @@ func (tc *TestCase) Test(t *testing.T) error {
 	return nil
 }
 
+// serveTestPlugin serves impl over go-plugin RPC until ctx is canceled and returns a client for it; the returned
+// channel is closed once the server has exited
+func serveTestPlugin(ctx context.Context, impl *RpcPlugin) (*rolloutsPlugin.TrafficRouterPluginRPC, chan struct{}, error) {
+	var pluginMap = map[string]goPlugin.Plugin{
+		"RpcTrafficRouterPlugin": &rolloutsPlugin.RpcTrafficRouterPlugin{Impl: impl},
+	}
+
+	ch := make(chan *goPlugin.ReattachConfig, 1)
+	closeCh := make(chan struct{})
+	go goPlugin.Serve(&goPlugin.ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         pluginMap,
+		Test: &goPlugin.ServeTestConfig{
+			Context:          ctx,
+			ReattachConfigCh: ch,
+			CloseCh:          closeCh,
+		},
+	})
+
+	var config *goPlugin.ReattachConfig
+	select {
+	case config = <-ch:
+	case <-time.After(2000 * time.Millisecond):
+		return nil, nil, fmt.Errorf("should've received reattach")
+	}
+	if config == nil {
+		return nil, nil, fmt.Errorf("config should not be nil")
+	}
+
+	// Connect!
+	c := goPlugin.NewClient(&goPlugin.ClientConfig{
+		Cmd:             nil,
+		HandshakeConfig: testHandshake,
+		Plugins:         pluginMap,
+		Reattach:        config,
+	})
+	client, err := c.Client()
+	if err != nil {
+		return nil, nil, fmt.Errorf("err: %s", err)
+	}
+
+	// Pinging should work
+	if err := client.Ping(); err != nil {
+		return nil, nil, fmt.Errorf("should not err: %s", err)
+	}
+
+	// Kill which should do nothing
+	c.Kill()
+	if err := client.Ping(); err != nil {
+		return nil, nil, fmt.Errorf("should not err: %s", err)
+	}
+
+	// Request the plugin
+	raw, err := client.Dispense("RpcTrafficRouterPlugin")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return raw.(*rolloutsPlugin.TrafficRouterPluginRPC), closeCh, nil
+}
+
 func TestRollouts(t *testing.T) {
 
 	err := filepath.Walk("testfiles",
@@ -267,6 +296,7 @@ func TestRollouts(t *testing.T) {
 				tc.asserionMap = make(map[int]*StepAssertion)
 
 				for _, sa := range tc.StepAssertions {
+					sa := sa
 					if existingSa, ok := tc.asserionMap[sa.Step]; ok {
 						existingSa.Assert = append(existingSa.Assert, sa.Assert...)
 					} else {
@@ -284,3 +314,1384 @@ func TestRollouts(t *testing.T) {
 
 	assert.Empty(t, err)
 }
+
+// newTestPlugin creates a plugin backed by fake clients: the Gloo client serves glooObjs, the Kubernetes client
+// serves the stable and canary Services of rollout and the Rollouts client serves rollout
+func newTestPlugin(rollout *v1alpha1.Rollout, glooObjs ...k8sclient.Object) *RpcPlugin {
+	return &RpcPlugin{
+		LogCtx:         log.WithFields(log.Fields{"plugin": "trafficrouter"}),
+		Client:         mocks.NewGlooFakeClient(glooObjs...),
+		KubeClient:     k8sfake.NewSimpleClientset(rolloutServices(rollout)...),
+		RolloutsClient: rolloutsfake.NewSimpleClientset(rollout),
+		Recorder:       record.NewFakeRecorder(100),
+	}
+}
+
+// rolloutServices returns the stable and canary Services of the canary rollouts
+func rolloutServices(rollouts ...*v1alpha1.Rollout) []runtime.Object {
+	var services []runtime.Object
+	for _, rollout := range rollouts {
+		if canary := rollout.Spec.Strategy.Canary; canary != nil {
+			for _, name := range []string{canary.StableService, canary.CanaryService} {
+				services = append(services, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: rollout.Namespace}})
+			}
+		}
+	}
+	return services
+}
+
+// getRouteTable reads a RouteTable back from the Gloo client of r
+func getRouteTable(t *testing.T, r *RpcPlugin, name, namespace string) *networkv2.RouteTable {
+	t.Helper()
+	rt, err := r.Client.RouteTables().GetRouteTable(context.Background(), name, namespace)
+	if err != nil {
+		t.Fatalf("failed to get RouteTable %s.%s: %s", namespace, name, err)
+	}
+	return rt
+}
+
+// editRouteTable updates a RouteTable through the Gloo client of r, e.g. to simulate a change made by another client
+func editRouteTable(t *testing.T, r *RpcPlugin, name, namespace string, edit func(rt *networkv2.RouteTable)) {
+	t.Helper()
+	rt := getRouteTable(t, r, name, namespace)
+	patch := k8sclient.MergeFrom(rt.DeepCopy())
+	edit(rt)
+	if err := r.Client.RouteTables().PatchRouteTable(context.Background(), rt, patch); err != nil {
+		t.Fatalf("failed to patch RouteTable %s.%s: %s", namespace, name, err)
+	}
+}
+
+// loadTestCase reads a test case from testfiles
+func loadTestCase(t *testing.T, fileName string) *TestCase {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testfiles", fileName))
+	if err != nil {
+		t.Fatalf("failed to read test case %s: %s", fileName, err)
+	}
+	tc := &TestCase{fileName: fileName}
+	if err := yaml.Unmarshal(data, tc); err != nil {
+		t.Fatalf("failed to unmarshal test case %s: %s", fileName, err)
+	}
+	return tc
+}
+
+// setPluginConfig replaces the plugin config of the test case Rollout
+func (tc *TestCase) setPluginConfig(config string) {
+	tc.Rollout.Spec.Strategy.Canary.TrafficRouting.Plugins[PluginName] = json.RawMessage(config)
+}
+
+// newPlugin creates and initializes a test plugin for the test case Rollout. The Gloo client serves glooObjs, or the
+// test case RouteTable if there are none; configure, if not nil, is called before the plugin is initialized
+func (tc *TestCase) newPlugin(t *testing.T, configure func(r *RpcPlugin), glooObjs ...k8sclient.Object) *RpcPlugin {
+	t.Helper()
+	if len(glooObjs) == 0 {
+		glooObjs = []k8sclient.Object{tc.RouteTable.DeepCopy()}
+	}
+	r := newTestPlugin(tc.Rollout, glooObjs...)
+	if configure != nil {
+		configure(r)
+	}
+	if rpcErr := r.InitPlugin(); rpcErr.HasError() {
+		t.Fatalf("failed to initialize plugin: %s", rpcErr)
+	}
+	return r
+}
+
+// routeTable reads the test case RouteTable back from the Gloo client of r
+func (tc *TestCase) routeTable(t *testing.T, r *RpcPlugin) *networkv2.RouteTable {
+	t.Helper()
+	return getRouteTable(t, r, tc.RouteTable.Name, tc.RouteTable.Namespace)
+}
+
+// routeTableCopies returns copies of the test case RouteTable with the given names, labeled app: demo
+func (tc *TestCase) routeTableCopies(names ...string) []k8sclient.Object {
+	var rts []k8sclient.Object
+	for _, name := range names {
+		rt := tc.RouteTable.DeepCopy()
+		rt.Name = name
+		rt.Labels = map[string]string{"app": "demo"}
+		rts = append(rts, rt)
+	}
+	return rts
+}
+
+// recordedEvents drains the Events recorded by the fake recorder of r and returns those with the given reason
+func recordedEvents(r *RpcPlugin, reason string) []string {
+	recorder := r.Recorder.(*record.FakeRecorder)
+	var events []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, reason) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// hasRolloutSeries returns true if the plugin metric with the given name has a series of the Rollout
+func hasRolloutSeries(t *testing.T, name, rollout string) bool {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "rollout" && label.GetValue() == rollout {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// metricValue returns the value of the plugin counter or gauge, or the sample count of the histogram, with the given
+// name and labels, or 0 if it has not been recorded
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %s", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			switch {
+			case m.GetCounter() != nil:
+				return m.GetCounter().GetValue()
+			case m.GetHistogram() != nil:
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+			return m.GetGauge().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestSetWeightSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+
+	r := tc.newPlugin(t, nil)
+	exporter.Reset()
+
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 4)
+	attrs := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	// child spans end first
+	get, patch, update, call := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, "RouteTable.Get", get.Name)
+	assert.Equal(t, call.SpanContext.SpanID(), get.Parent.SpanID())
+
+	assert.Equal(t, "RouteTable.Patch", patch.Name)
+	assert.Equal(t, update.SpanContext.SpanID(), patch.Parent.SpanID())
+	assert.Equal(t, "application/json-patch+json", attrs(patch)["patch.type"].AsString())
+
+	assert.Equal(t, "UpdateRouteTable", update.Name)
+	assert.Equal(t, call.SpanContext.SpanID(), update.Parent.SpanID())
+	assert.Equal(t, []string{"demo: stable=90 canary=10"}, attrs(update)["weights"].AsStringSlice())
+
+	assert.Equal(t, "SetWeight", call.Name)
+	assert.Equal(t, "demo", attrs(call)["rollout.name"].AsString())
+	assert.Equal(t, int64(10), attrs(call)["weight.desired"].AsInt64())
+	assert.Equal(t, []string{"gloo-mesh/default"}, attrs(call)["routetables"].AsStringSlice())
+}
+
+func TestMetrics(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.Rollout.Name = "metrics"
+	r := tc.newPlugin(t, nil)
+	calls := func(method, result string) float64 {
+		return metricValue(t, "glooplatform_rollouts_plugin_calls_total", map[string]string{"method": method, "result": result})
+	}
+	patches := func(result string) float64 {
+		return metricValue(t, "glooplatform_rollouts_plugin_routetable_patch_duration_seconds", map[string]string{"result": result})
+	}
+	rolloutLabels := map[string]string{"namespace": "gloo-mesh", "rollout": "metrics"}
+	weight := func(destination string) float64 {
+		return metricValue(t, "glooplatform_rollouts_plugin_route_weight", map[string]string{
+			"namespace": "gloo-mesh", "rollout": "metrics", "routetable_namespace": "gloo-mesh", "routetable": "default", "route": "demo", "destination": destination,
+		})
+	}
+
+	successes, failures, patched, failedPatches := calls("SetWeight", "success"), calls("SetWeight", "error"), patches("success"), patches("error")
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, 1.0, calls("SetWeight", "success")-successes)
+	assert.Equal(t, 1.0, patches("success")-patched)
+	assert.Equal(t, 90.0, weight("stable"))
+	assert.Equal(t, 10.0, weight("canary"))
+	assert.Equal(t, 1.0, metricValue(t, "glooplatform_rollouts_plugin_matched_routetables", rolloutLabels))
+	assert.Equal(t, 1.0, metricValue(t, "glooplatform_rollouts_plugin_matched_routes", rolloutLabels))
+
+	// the weights are those last written
+	r.Client = failingPatchClient{NetworkV2ClientSet: r.Client, names: []string{"default"}}
+	assert.True(t, r.SetWeight(tc.Rollout, 30, nil).HasError())
+	assert.Equal(t, 1.0, calls("SetWeight", "error")-failures)
+	assert.Equal(t, 1.0, patches("error")-failedPatches)
+	assert.Equal(t, 90.0, weight("stable"))
+	assert.Equal(t, 10.0, weight("canary"))
+
+	verified := calls("VerifyWeight", "success")
+	_, rpcErr := r.VerifyWeight(tc.Rollout, 10, nil)
+	assert.False(t, rpcErr.HasError())
+	assert.Equal(t, 1.0, calls("VerifyWeight", "success")-verified)
+
+	// the series of a Rollout are dropped once its routes are no longer managed
+	r.Client = r.Client.(failingPatchClient).NetworkV2ClientSet
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.Equal(t, 0.0, weight("stable"))
+	assert.Equal(t, 0.0, metricValue(t, "glooplatform_rollouts_plugin_matched_routetables", rolloutLabels))
+
+	// and stay gone when a fully promoted Rollout is set to 0 on the reconciles that follow
+	tc.Rollout.Status.StableRS, tc.Rollout.Status.CurrentPodHash = "stable-hash", "stable-hash"
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.Equal(t, uint32(100), tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations[0].Weight)
+	for _, name := range []string{"glooplatform_rollouts_plugin_route_weight", "glooplatform_rollouts_plugin_matched_routetables", "glooplatform_rollouts_plugin_matched_routes"} {
+		assert.False(t, hasRolloutSeries(t, name, "metrics"), name)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.Rollout.Annotations = map[string]string{"rollout.argoproj.io/revision": "3"}
+	stepIndex := int32(1)
+	tc.Rollout.Status.CurrentStepIndex = &stepIndex
+
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(log.DebugLevel)
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.LogCtx = logger.WithField("plugin", "trafficrouter")
+	})
+
+	// every line logged for a call carries the Rollout and its step
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.NotEmpty(t, hook.AllEntries())
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, log.Fields{
+			"plugin":    "trafficrouter",
+			"namespace": "gloo-mesh",
+			"rollout":   "demo",
+			"revision":  "3",
+			"stepIndex": int32(1),
+		}, entry.Data, entry.Message)
+	}
+	assert.Equal(t, "patched route table gloo-mesh.default", hook.LastEntry().Message)
+	assert.Equal(t, log.DebugLevel, hook.LastEntry().Level)
+
+	// the matching and patching details are only logged at debug level
+	hook.Reset()
+	logger.SetLevel(log.InfoLevel)
+	assert.False(t, r.SetWeight(tc.Rollout, 20, nil).HasError())
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestRouteOwnership(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.RouteTable.Annotations = map[string]string{
+		AnnotationRouteOwners: `{"demo":"gloo-mesh/other"}`,
+	}
+
+	other := &v1alpha1.Rollout{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "gloo-mesh"}}
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.RolloutsClient = rolloutsfake.NewSimpleClientset(tc.Rollout, other)
+	})
+
+	// the route is managed by a live Rollout
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "route demo in RouteTable gloo-mesh.default is managed by Rollout gloo-mesh/other")
+	assert.Len(t, tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations, 1)
+
+	// the claim of a deleted Rollout is taken over
+	assert.NoError(t, r.RolloutsClient.ArgoprojV1alpha1().Rollouts("gloo-mesh").Delete(context.Background(), "other", metav1.DeleteOptions{}))
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, `{"demo":"gloo-mesh/demo"}`, tc.routeTable(t, r).Annotations[AnnotationRouteOwners])
+
+	// claims are released when the routes are no longer managed, and setting the weight of the fully promoted
+	// Rollout to 0 afterwards does not claim them again
+	tc.Rollout.Status.StableRS, tc.Rollout.Status.CurrentPodHash = "stable-hash", "stable-hash"
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.NotContains(t, tc.routeTable(t, r).Annotations, AnnotationRouteOwners)
+
+	// so later reconciles leave the RouteTable as is
+	resourceVersion := tc.routeTable(t, r).ResourceVersion
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.Equal(t, resourceVersion, tc.routeTable(t, r).ResourceVersion)
+
+	// a Rollout with an update in progress claims the routes even without canary weight
+	tc.Rollout.Status.CurrentPodHash = "canary-hash"
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.Equal(t, `{"demo":"gloo-mesh/demo"}`, tc.routeTable(t, r).Annotations[AnnotationRouteOwners])
+}
+
+func TestRouteOwnershipAcrossClusters(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	// a Rollout with the same namespace and name in another cluster sharing the RouteTable
+	tc.RouteTable.Annotations = map[string]string{
+		AnnotationRouteOwners: `{"demo":"west/gloo-mesh/demo"}`,
+	}
+
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.RolloutsClient = rolloutsfake.NewSimpleClientset(tc.Rollout)
+		r.Settings = &config.Settings{ClusterName: "east"}
+	})
+
+	// the Rollout of the other cluster cannot be looked up, so it is assumed to exist
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "route demo in RouteTable gloo-mesh.default is managed by Rollout west/gloo-mesh/demo")
+
+	// once released, the route is claimed with the cluster name
+	editRouteTable(t, r, "default", "gloo-mesh", func(rt *networkv2.RouteTable) {
+		delete(rt.Annotations, AnnotationRouteOwners)
+	})
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, `{"demo":"east/gloo-mesh/demo"}`, tc.routeTable(t, r).Annotations[AnnotationRouteOwners])
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.NotContains(t, tc.routeTable(t, r).Annotations, AnnotationRouteOwners)
+}
+
+func TestWeightDrift(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+
+	r := tc.newPlugin(t, nil)
+	weights := func() (uint32, uint32) {
+		destinations := tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations
+		return destinations[0].Weight, destinations[1].Weight
+	}
+	drift := func() {
+		// e.g. a GitOps sync resetting the RouteTable to 100% stable
+		editRouteTable(t, r, "default", "gloo-mesh", func(rt *networkv2.RouteTable) {
+			destinations := rt.Spec.Http[0].GetForwardTo().Destinations
+			destinations[0].Weight = 100
+			destinations[1].Weight = 0
+		})
+	}
+	setDriftAction := func(action string) {
+		tc.setPluginConfig(fmt.Sprintf(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"driftAction":%q}`, action))
+	}
+	driftEvents := func() int {
+		return len(recordedEvents(r, EventReasonWeightDrift))
+	}
+
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, 0, driftEvents())
+
+	// reapply
+	drift()
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	stable, canary := weights()
+	assert.Equal(t, uint32(90), stable)
+	assert.Equal(t, uint32(10), canary)
+	assert.Equal(t, 1, driftEvents())
+
+	// verify
+	setDriftAction("verify")
+	drift()
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	stable, canary = weights()
+	assert.Equal(t, uint32(100), stable)
+	assert.Equal(t, uint32(0), canary)
+	assert.Equal(t, 1, driftEvents())
+	verified, rpcErr := r.VerifyWeight(tc.Rollout, 10, nil)
+	assert.False(t, rpcErr.HasError())
+	assert.Equal(t, pluginTypes.NotVerified, verified)
+
+	// fail
+	setDriftAction("fail")
+	rpcErr = r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "route demo: expected stable 90, canary 10; found stable 100, canary 0")
+	assert.Equal(t, 1, driftEvents())
+
+	// the applied weights are kept on the RouteTable, so a restarted plugin detects the drift as well
+	assert.Equal(t, `{"demo":{"stable":90,"canary":10}}`, tc.routeTable(t, r).Annotations[AnnotationAppliedWeights])
+	restarted := tc.newPlugin(t, func(restarted *RpcPlugin) {
+		restarted.Client = r.Client
+	})
+	rpcErr = restarted.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "route demo: expected stable 90, canary 10; found stable 100, canary 0")
+
+	// released routes are no longer checked for drift
+	assert.False(t, restarted.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.NotContains(t, tc.routeTable(t, r).Annotations, AnnotationAppliedWeights)
+}
+
+func TestWeightEvents(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	scheme, err := gloo.NewScheme()
+	assert.NoError(t, err)
+	glooClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.RouteTable.DeepCopy()).Build()
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = gloo.NewNetworkV2ClientSetForClient(glooClient)
+		r.Settings = &config.Settings{RouteTableEvents: true}
+	})
+	routeTableEvents := func() []string {
+		events := &corev1.EventList{}
+		assert.NoError(t, glooClient.List(context.Background(), events, k8sclient.InNamespace("gloo-mesh")))
+		var result []string
+		for _, e := range events.Items {
+			assert.Equal(t, "RouteTable", e.InvolvedObject.Kind)
+			assert.Equal(t, "default", e.InvolvedObject.Name)
+			result = append(result, fmt.Sprintf("%s %s %s", e.Type, e.Reason, e.Message))
+		}
+		return result
+	}
+
+	// an Event for each changed route, on the Rollout and on the RouteTable
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, []string{"Normal GlooPlatformAPIWeightUpdated updated RouteTable gloo-mesh.default route demo: stable 0 -> 90, canary 0 -> 10"}, recordedEvents(r, EventReasonWeightUpdated))
+	assert.Equal(t, []string{"Normal GlooPlatformAPIWeightUpdated Rollout gloo-mesh.demo: updated RouteTable gloo-mesh.default route demo: stable 0 -> 90, canary 0 -> 10"}, routeTableEvents())
+
+	// none if nothing changed
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Empty(t, recordedEvents(r, ""))
+	assert.Len(t, routeTableEvents(), 1)
+
+	// a warning naming the attempted change if the update failed
+	r.Client = failingPatchClient{NetworkV2ClientSet: r.Client, names: []string{"default"}}
+	assert.True(t, r.SetWeight(tc.Rollout, 30, nil).HasError())
+	assert.Equal(t, []string{"Warning GlooPlatformAPIUpdateError failed to update RouteTable gloo-mesh.default (route demo: stable 90 -> 70, canary 10 -> 30): injected failure"}, recordedEvents(r, ""))
+	assert.Len(t, routeTableEvents(), 2)
+}
+
+func TestDryRun(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"dryRun":true}`)
+
+	r := tc.newPlugin(t, nil)
+
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	events := recordedEvents(r, EventReasonDryRun)
+	assert.Len(t, events, 1)
+	event := events[0]
+	assert.Contains(t, event, "dry run: would update RouteTable gloo-mesh.default (route demo: stable 0 -> 90, canary 0 -> 10)")
+	assert.Contains(t, event, `"path":"/spec/http/0/forwardTo/destinations/-"`)
+	assert.NotContains(t, event, AnnotationUpdatedAt)
+	assert.True(t, tc.RouteTable.Spec.Equal(&tc.routeTable(t, r).Spec))
+
+	// the same patch is computed on every reconcile but only recorded once
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Empty(t, recordedEvents(r, EventReasonDryRun))
+	assert.False(t, r.SetWeight(tc.Rollout, 20, nil).HasError())
+	events = recordedEvents(r, EventReasonDryRun)
+	assert.Len(t, events, 1)
+	assert.Contains(t, events[0], "dry run: would update RouteTable gloo-mesh.default (route demo: stable 0 -> 80, canary 0 -> 20)")
+
+	// nothing was applied, so there is nothing to drift from
+	assert.NotContains(t, tc.routeTable(t, r).Annotations, AnnotationAppliedWeights)
+}
+
+func TestSetWeightOutOfRange(t *testing.T) {
+	tc := loadTestCase(t, "13-full-promotion.yaml")
+
+	r := tc.newPlugin(t, nil)
+	assert.Equal(t, "desired weight -1 is out of range [0, 100]", r.SetWeight(tc.Rollout, -1, nil).ErrorString)
+	assert.Equal(t, "desired weight 101 is out of range [0, 100]", r.SetWeight(tc.Rollout, 101, nil).ErrorString)
+	assert.Len(t, tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations, 1)
+	assert.False(t, r.SetWeight(tc.Rollout, 100, nil).HasError())
+
+	// desired weights are relative to maxTrafficWeight
+	tc = loadTestCase(t, "16-max-traffic-weight.yaml")
+	r = tc.newPlugin(t, nil)
+	assert.Equal(t, "desired weight 1001 is out of range [0, 1000]", r.SetWeight(tc.Rollout, 1001, nil).ErrorString)
+	assert.False(t, r.SetWeight(tc.Rollout, 1000, nil).HasError())
+}
+
+func TestCollapseAfterPromotion(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"collapseAfterPromotion":{"enabled":true,"removeWeight":true}}`)
+
+	r := tc.newPlugin(t, nil)
+	destinations := func() []*solov2.DestinationReference {
+		return tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations
+	}
+
+	// an aborted Rollout is not collapsed
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	tc.Rollout.Status.StableRS, tc.Rollout.Status.CurrentPodHash = "abc", "def"
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.Len(t, destinations(), 2)
+
+	// a fully promoted Rollout is. Argo Rollouts sets its weight to 0 right after removing its managed routes, which
+	// leaves the collapsed route as is
+	tc.Rollout.Status.CurrentPodHash = "abc"
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.Len(t, destinations(), 1)
+	assert.Equal(t, "stable", destinations()[0].GetRef().GetName())
+	assert.Equal(t, uint32(0), destinations()[0].Weight)
+
+	collapsed := recordedEvents(r, EventReasonRoutesCollapsed)
+	assert.Len(t, collapsed, 1)
+	assert.Contains(t, collapsed[0], "collapsed route demo in RouteTable gloo-mesh.default: removed destination canary without weight and the weight of destination stable")
+
+	// later reconciles neither write the RouteTable nor record Events
+	resourceVersion := tc.routeTable(t, r).ResourceVersion
+	recordedEvents(r, "")
+	assert.False(t, r.RemoveManagedRoutes(tc.Rollout).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 0, nil).HasError())
+	assert.Equal(t, resourceVersion, tc.routeTable(t, r).ResourceVersion)
+	assert.Empty(t, recordedEvents(r, ""))
+}
+
+func TestCollapseRoutesAfterSwap(t *testing.T) {
+	dest := func(name string, weight uint32) *solov2.DestinationReference {
+		return &solov2.DestinationReference{
+			RefKind: &solov2.DestinationReference_Ref{Ref: &solov2.ObjectReference{Name: name}},
+			Weight:  weight,
+		}
+	}
+	stable, canary, legacy := dest("stable", 0), dest("canary", 100), dest("legacy", 20)
+	route := &networkv2.HTTPRoute{
+		Name: "demo",
+		ActionType: &networkv2.HTTPRoute_ForwardTo{ForwardTo: &networkv2.ForwardToAction{
+			Destinations: []*solov2.DestinationReference{stable, canary, legacy},
+		}},
+	}
+	rt := &GlooMatchedRouteTable{
+		RouteTable: &networkv2.RouteTable{},
+		HttpRoutes: []*GlooMatchedHttpRoutes{{
+			HttpRoute:    route,
+			Destinations: &GlooDestinations{StableOrActiveDestination: stable, CanaryOrPreviewDestination: canary},
+		}},
+	}
+
+	assert.Len(t, collapseRoutes(rt, true), 1)
+	assert.Equal(t, []*solov2.DestinationReference{canary, legacy}, route.GetForwardTo().Destinations)
+	// the weights matter while the route has other destinations
+	assert.Equal(t, uint32(100), canary.Weight)
+}
+
+// failingPatchClient fails patches of the RouteTables with the given names
+type failingPatchClient struct {
+	gloo.NetworkV2ClientSet
+	names []string
+	// optional; called around each patch
+	before, after func()
+}
+
+func (c failingPatchClient) RouteTables() gloo.RouteTableClient {
+	return failingPatchRouteTableClient{RouteTableClient: c.NetworkV2ClientSet.RouteTables(), c: c}
+}
+
+type failingPatchRouteTableClient struct {
+	gloo.RouteTableClient
+	c failingPatchClient
+}
+
+func (c failingPatchRouteTableClient) PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	if c.c.before != nil {
+		c.c.before()
+		defer c.c.after()
+	}
+	for _, name := range c.c.names {
+		if obj.Name == name {
+			return fmt.Errorf("injected failure")
+		}
+	}
+	return c.RouteTableClient.PatchRouteTable(ctx, obj, patch, opts...)
+}
+
+// hookedPatchClient calls hook before each RouteTable patch or apply; an error returned by hook fails the write
+type hookedPatchClient struct {
+	gloo.NetworkV2ClientSet
+	hook func(rt *networkv2.RouteTable) error
+}
+
+func (c hookedPatchClient) RouteTables() gloo.RouteTableClient {
+	return hookedPatchRouteTableClient{RouteTableClient: c.NetworkV2ClientSet.RouteTables(), hook: c.hook}
+}
+
+type hookedPatchRouteTableClient struct {
+	gloo.RouteTableClient
+	hook func(rt *networkv2.RouteTable) error
+}
+
+// PatchRouteTable fails once ctx is done, as a request to the API server would
+func (c hookedPatchRouteTableClient) PatchRouteTable(ctx context.Context, obj *networkv2.RouteTable, patch k8sclient.Patch, opts ...k8sclient.PatchOption) error {
+	if err := c.hook(obj); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.RouteTableClient.PatchRouteTable(ctx, obj, patch, opts...)
+}
+
+func (c hookedPatchRouteTableClient) ApplyRouteTable(ctx context.Context, obj *networkv2.RouteTable, opts ...k8sclient.PatchOption) error {
+	if err := c.hook(obj); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.RouteTableClient.ApplyRouteTable(ctx, obj, opts...)
+}
+
+func TestPatchRetry(t *testing.T) {
+	defer func(backoff wait.Backoff) { patchRetryBackoff = backoff }(patchRetryBackoff)
+	patchRetryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+	retries := func() float64 {
+		return metricValue(t, "glooplatform_rollouts_plugin_routetable_patch_retries_total", nil)
+	}
+	conflicts := func() float64 {
+		return metricValue(t, "glooplatform_rollouts_plugin_routetable_patch_conflicts_total", nil)
+	}
+
+	// another client inserts a route before the matched route between the read and the first patch: the test of
+	// the route name fails, and the retry patches the route at its new index
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	var patches int
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		live := r.Client.RouteTables()
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			patches++
+			if patches > 1 {
+				return nil
+			}
+			current, err := live.GetRouteTable(context.Background(), rt.Name, rt.Namespace)
+			assert.NoError(t, err)
+			patch := k8sclient.MergeFrom(current.DeepCopy())
+			current.Spec.Http = append([]*networkv2.HTTPRoute{{Name: "other"}}, current.Spec.Http...)
+			return live.PatchRouteTable(context.Background(), current, patch)
+		}}
+	})
+	retriesBefore, conflictsBefore := retries(), conflicts()
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, 2, patches)
+	assert.Equal(t, 1.0, retries()-retriesBefore)
+	assert.Equal(t, 1.0, conflicts()-conflictsBefore)
+	rt := tc.routeTable(t, r)
+	assert.Equal(t, "other", rt.Spec.Http[0].Name)
+	destinations := rt.Spec.Http[1].GetForwardTo().Destinations
+	assert.Len(t, destinations, 2)
+	assert.Equal(t, uint32(90), destinations[0].Weight)
+	assert.Equal(t, uint32(10), destinations[1].Weight)
+
+	// the patch is given up on once the backoff is exhausted
+	tc = loadTestCase(t, "10-basic-canary.yaml")
+	patches = 0
+	r = tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			patches++
+			return apierrors.NewConflict(networkv2.Resource("routetables"), rt.Name, fmt.Errorf("the object has been modified"))
+		}}
+	})
+	retriesBefore, conflictsBefore = retries(), conflicts()
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "failed to patch RouteTable gloo-mesh.default: Operation cannot be fulfilled on routetables.networking.gloo.solo.io \"default\": the object has been modified")
+	assert.Equal(t, 3, patches)
+	assert.Equal(t, 2.0, retries()-retriesBefore)
+	assert.Equal(t, 3.0, conflicts()-conflictsBefore)
+	assert.True(t, tc.RouteTable.Spec.Equal(&tc.routeTable(t, r).Spec))
+
+	// a patch rejected by validation is not retried
+	tc = loadTestCase(t, "10-basic-canary.yaml")
+	patches = 0
+	r = tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			patches++
+			return apierrors.NewInvalid(networkv2.RouteTableGVK.GroupKind(), rt.Name, field.ErrorList{
+				field.Invalid(field.NewPath("spec", "http").Index(0).Child("forwardTo"), nil, "injected failure"),
+			})
+		}}
+	})
+	retriesBefore, conflictsBefore = retries(), conflicts()
+	rpcErr = r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "failed to patch RouteTable gloo-mesh.default: RouteTable.networking.gloo.solo.io \"default\" is invalid")
+	assert.Equal(t, 1, patches)
+	assert.Equal(t, 0.0, retries()-retriesBefore)
+	assert.Equal(t, 0.0, conflicts()-conflictsBefore)
+}
+
+func TestServerSideApplyFields(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"serverSideApply":true}`)
+	tc.RouteTable.Annotations = map[string]string{"team": "a"}
+	tc.RouteTable.Spec.Hosts = []string{"demo.example.com"}
+
+	var applied []*networkv2.RouteTable
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			applied = append(applied, rt.DeepCopy())
+			return nil
+		}}
+	})
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	rt := tc.routeTable(t, r)
+	assert.Len(t, rt.Spec.Http[0].GetForwardTo().Destinations, 2)
+	assert.Equal(t, "a", rt.Annotations["team"])
+	assert.Equal(t, []string{"demo.example.com"}, rt.Spec.Hosts)
+
+	// the fields the plugin manages are the fields of the apply request; spec.http is atomic, so the plugin manages
+	// it as a whole
+	assert.Len(t, applied, 1)
+	data, err := json.Marshal(applied[0])
+	assert.NoError(t, err)
+	u := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(data, &u))
+	tv, err := typed.DeducedParseableType.FromUnstructured(u)
+	assert.NoError(t, err)
+	fields, err := tv.ToFieldSet()
+	assert.NoError(t, err)
+	var managed []string
+	fields.Leaves().Iterate(func(path fieldpath.Path) {
+		if p := path.String(); strings.HasPrefix(p, ".spec") || strings.HasPrefix(p, ".metadata.annotations") {
+			managed = append(managed, p)
+		}
+	})
+	expected := []string{".spec.http"}
+	for _, key := range pluginAnnotations {
+		expected = append(expected, ".metadata.annotations."+key)
+	}
+	assert.ElementsMatch(t, expected, managed)
+}
+
+// TestServerSideApplyOwnership runs against an API server started by envtest, as the fake client does not track
+// field managers; it is skipped unless KUBEBUILDER_ASSETS points at the envtest binaries.
+func TestServerSideApplyOwnership(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("testdata", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("failed to start envtest: %s", err)
+	}
+	defer func() { assert.NoError(t, env.Stop()) }()
+	scheme, err := gloo.NewScheme()
+	assert.NoError(t, err)
+	c, err := k8sclient.New(cfg, k8sclient.Options{Scheme: scheme})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	assert.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tc.RouteTable.Namespace}}))
+	// another field manager, e.g. Argo CD, applies the RouteTable and owns spec.http
+	gitops := func() error {
+		rt := tc.RouteTable.DeepCopy()
+		rt.SetGroupVersionKind(networkv2.RouteTableGVK)
+		return c.Patch(ctx, rt, k8sclient.Apply, k8sclient.FieldOwner("argocd"))
+	}
+	assert.NoError(t, gitops())
+	httpOwners := func() []string {
+		rt := &networkv2.RouteTable{}
+		assert.NoError(t, c.Get(ctx, k8sclient.ObjectKeyFromObject(tc.RouteTable), rt))
+		var owners []string
+		for _, entry := range rt.ManagedFields {
+			if entry.FieldsV1 != nil && strings.Contains(string(entry.FieldsV1.Raw), `"f:http"`) {
+				owners = append(owners, entry.Manager)
+			}
+		}
+		return owners
+	}
+
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = gloo.NewNetworkV2ClientSetForClient(c)
+	})
+	// the plugin does not take spec.http over unless told to
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"serverSideApply":true}`)
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "server-side apply as glooplatform-rollouts-plugin conflicts with another field manager")
+	assert.Equal(t, []string{"argocd"}, httpOwners())
+	assert.Len(t, tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations, 1)
+
+	// with forceOwnership, it does, and the other field manager conflicts with the plugin in turn
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"serverSideApply":true,"forceOwnership":true}`)
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.Equal(t, []string{gloo.FieldManager}, httpOwners())
+	destinations := tc.routeTable(t, r).Spec.Http[0].GetForwardTo().Destinations
+	assert.Len(t, destinations, 2)
+	assert.Equal(t, uint32(90), destinations[0].Weight)
+	assert.Equal(t, uint32(10), destinations[1].Weight)
+	assert.True(t, apierrors.IsConflict(gitops()))
+}
+
+// blockingClient blocks RouteTable reads until their context is done, like an unresponsive API server
+type blockingClient struct {
+	gloo.NetworkV2ClientSet
+}
+
+func (c blockingClient) RouteTables() gloo.RouteTableClient {
+	return blockingRouteTableClient{RouteTableClient: c.NetworkV2ClientSet.RouteTables()}
+}
+
+type blockingRouteTableClient struct {
+	gloo.RouteTableClient
+}
+
+func (c blockingRouteTableClient) GetRouteTable(ctx context.Context, _ string, _ string) (*networkv2.RouteTable, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c blockingRouteTableClient) ListRouteTable(ctx context.Context, _ ...k8sclient.ListOption) ([]*networkv2.RouteTable, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = blockingClient{NetworkV2ClientSet: r.Client}
+		r.Settings = &config.Settings{RequestTimeout: 50 * time.Millisecond}
+	})
+
+	start := time.Now()
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, strings.HasPrefix(rpcErr.ErrorString, "timed out talking to Gloo API: "), rpcErr.ErrorString)
+	assert.Contains(t, rpcErr.ErrorString, "context deadline exceeded")
+
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"driftAction":"verify"}`)
+	_, rpcErr = r.VerifyWeight(tc.Rollout, 10, nil)
+	assert.True(t, strings.HasPrefix(rpcErr.ErrorString, "timed out talking to Gloo API: "), rpcErr.ErrorString)
+
+	// other errors are reported as is
+	r.Client = failingPatchClient{NetworkV2ClientSet: r.Client.(blockingClient).NetworkV2ClientSet, names: []string{"default"}}
+	assert.Equal(t, "failed to patch RouteTable gloo-mesh.default: injected failure", r.SetWeight(tc.Rollout, 10, nil).ErrorString)
+}
+
+func TestIsPatchConflict(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		conflict bool
+	}{
+		{apierrors.NewConflict(networkv2.Resource("routetables"), "default", fmt.Errorf("the object has been modified")), true},
+		// JSON patches that no longer apply, such as failed test operations, as reported by the API server
+		{apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "", schema.GroupResource{}, "", "testing value /spec/http/0/name failed: test failed", 0, false), true},
+		{fmt.Errorf("failed to patch: %w", apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "", schema.GroupResource{}, "", "doc is missing path: /spec/http/3", 0, false)), true},
+		// patched RouteTables rejected by validation
+		{apierrors.NewInvalid(networkv2.RouteTableGVK.GroupKind(), "default", field.ErrorList{field.Required(field.NewPath("spec", "http").Index(0).Child("name"), "")}), false},
+		// other errors, whatever their message
+		{apierrors.NewBadRequest("testing value /spec/http/0/name failed"), false},
+		{fmt.Errorf("testing value /metadata/resourceVersion failed"), false},
+		{fmt.Errorf("injected failure"), false},
+	} {
+		assert.Equal(t, tc.conflict, isPatchConflict(tc.err), tc.err.Error())
+	}
+}
+
+func TestAtomicRollback(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"labels":{"app":"demo"},"namespace":"gloo-mesh"},"atomic":true}`)
+
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = failingPatchClient{NetworkV2ClientSet: r.Client, names: []string{"south"}}
+		// one at a time in name order, so that west is not updated
+		r.Settings = &config.Settings{PatchConcurrency: 1}
+	}, tc.routeTableCopies("east", "south", "west")...)
+
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Equal(t, "failed to patch RouteTable gloo-mesh.south: injected failure; RouteTables: gloo-mesh.east rolled back, gloo-mesh.south unchanged, gloo-mesh.west not updated", rpcErr.ErrorString)
+	for _, name := range []string{"east", "south", "west"} {
+		rt := getRouteTable(t, r, name, "gloo-mesh")
+		assert.True(t, tc.RouteTable.Spec.Equal(&rt.Spec), name)
+		assert.NotContains(t, rt.Annotations, AnnotationRouteOwners, name)
+		assert.NotContains(t, rt.Annotations, AnnotationAppliedWeights, name)
+	}
+}
+
+func TestAtomicRollbackKeepsOtherChanges(t *testing.T) {
+	defer func(backoff wait.Backoff) { patchRetryBackoff = backoff }(patchRetryBackoff)
+	patchRetryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"labels":{"app":"demo"},"namespace":"gloo-mesh"},"atomic":true}`)
+	otherRoute := &networkv2.HTTPRoute{Name: "other", ActionType: &networkv2.HTTPRoute_DirectResponse{DirectResponse: &networkv2.DirectResponseAction{Status: 404}}}
+
+	var direct *RpcPlugin
+	var east, west int32
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		direct = &RpcPlugin{Client: r.Client}
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			switch {
+			case rt.Name == "east" && atomic.AddInt32(&east, 1) == 1:
+				// another client adds a route between the read and the first patch, which is retried
+				editRouteTable(t, direct, "east", "gloo-mesh", func(rt *networkv2.RouteTable) {
+					rt.Spec.Http = append(rt.Spec.Http, otherRoute)
+				})
+			case rt.Name == "west" && atomic.AddInt32(&west, 1) == 1:
+				// another client changes the canary weight of south after its update
+				editRouteTable(t, direct, "south", "gloo-mesh", func(rt *networkv2.RouteTable) {
+					rt.Spec.Http[0].GetForwardTo().Destinations[1].Weight = 20
+				})
+				return fmt.Errorf("injected failure")
+			}
+			return nil
+		}}
+		r.Settings = &config.Settings{PatchConcurrency: 1}
+	}, tc.routeTableCopies("east", "south", "west")...)
+
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "failed to patch RouteTable gloo-mesh.west: injected failure; RouteTables: gloo-mesh.east rolled back, gloo-mesh.south updated, rollback failed: ")
+	assert.Contains(t, rpcErr.ErrorString, ", gloo-mesh.west unchanged")
+
+	// the rollback of east is based on the RouteTable the retried patch was based on and keeps the added route
+	assert.Equal(t, int32(3), east)
+	rt := getRouteTable(t, r, "east", "gloo-mesh")
+	assert.Len(t, rt.Spec.Http, 2)
+	assert.Len(t, rt.Spec.Http[0].GetForwardTo().Destinations, 1)
+	assert.True(t, otherRoute.Equal(rt.Spec.Http[1]))
+	assert.NotContains(t, rt.Annotations, AnnotationAppliedWeights)
+
+	// the canary weight of south changed since its update, so it is not rolled back
+	destinations := getRouteTable(t, r, "south", "gloo-mesh").Spec.Http[0].GetForwardTo().Destinations
+	assert.Equal(t, uint32(90), destinations[0].Weight)
+	assert.Equal(t, uint32(20), destinations[1].Weight)
+}
+
+func TestAtomicRollbackTimeout(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"labels":{"app":"demo"},"namespace":"gloo-mesh"},"atomic":true}`)
+
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = hookedPatchClient{NetworkV2ClientSet: r.Client, hook: func(rt *networkv2.RouteTable) error {
+			if rt.Name == "south" {
+				// the update of south outlives the plugin call
+				time.Sleep(100 * time.Millisecond)
+			}
+			return nil
+		}}
+		r.Settings = &config.Settings{PatchConcurrency: 1, RequestTimeout: 50 * time.Millisecond}
+	}, tc.routeTableCopies("east", "south")...)
+
+	// the rollback of east has a deadline of its own
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Contains(t, rpcErr.ErrorString, "failed to patch RouteTable gloo-mesh.south: context deadline exceeded; RouteTables: gloo-mesh.east rolled back, gloo-mesh.south unchanged")
+	assert.True(t, tc.RouteTable.Spec.Equal(&getRouteTable(t, r, "east", "gloo-mesh").Spec))
+}
+
+func TestConcurrentPatching(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.setPluginConfig(`{"routeTableSelector":{"labels":{"app":"demo"},"namespace":"gloo-mesh"}}`)
+
+	var names []string
+	for i := 0; i < 40; i++ {
+		names = append(names, fmt.Sprintf("rt-%02d", i))
+	}
+	rts := tc.routeTableCopies(names...)
+
+	var inFlight, maxInFlight int32
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.Client = failingPatchClient{
+			NetworkV2ClientSet: r.Client,
+			names:              []string{"rt-07", "rt-31"},
+			before: func() {
+				n := atomic.AddInt32(&inFlight, 1)
+				for {
+					m := atomic.LoadInt32(&maxInFlight)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			},
+			after: func() { atomic.AddInt32(&inFlight, -1) },
+		}
+		r.Settings = &config.Settings{PatchConcurrency: 4}
+	}, rts...)
+
+	// errors are attributed to each RouteTable and the other RouteTables are updated
+	rpcErr := r.SetWeight(tc.Rollout, 10, nil)
+	assert.Equal(t, "[failed to patch RouteTable gloo-mesh.rt-07: injected failure, failed to patch RouteTable gloo-mesh.rt-31: injected failure]", rpcErr.ErrorString)
+	assert.LessOrEqual(t, maxInFlight, int32(4))
+	assert.Greater(t, maxInFlight, int32(1))
+	for _, obj := range rts {
+		rt := getRouteTable(t, r, obj.GetName(), obj.GetNamespace())
+		updated := rt.Name != "rt-07" && rt.Name != "rt-31"
+		_, ok := rt.Annotations[AnnotationAppliedWeights]
+		assert.Equal(t, updated, ok, rt.Name)
+		assert.Equal(t, updated, len(rt.Spec.Http[0].GetForwardTo().Destinations) == 2, rt.Name)
+	}
+}
+
+func TestServiceValidation(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+
+	kubeClient := k8sfake.NewSimpleClientset(rolloutServices(tc.Rollout)...)
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.KubeClient = kubeClient
+	})
+	serviceGets := func() int {
+		n := 0
+		for _, action := range kubeClient.Actions() {
+			if action.GetVerb() == "get" && action.GetResource().Resource == "services" {
+				n++
+			}
+		}
+		kubeClient.ClearActions()
+		return n
+	}
+
+	// the Services are looked up once, not on every step
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 20, nil).HasError())
+	assert.Equal(t, 2, serviceGets())
+
+	// and again when the Rollout refers to other Services, until they are found
+	tc.Rollout.Spec.Strategy.Canary.CanaryService = "canary-v2"
+	assert.Contains(t, r.SetWeight(tc.Rollout, 20, nil).ErrorString, "canaryService canary-v2 not found in namespace gloo-mesh")
+	_, err := kubeClient.CoreV1().Services("gloo-mesh").Create(context.Background(), &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "canary-v2", Namespace: "gloo-mesh"}}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	kubeClient.ClearActions()
+	assert.False(t, r.SetWeight(tc.Rollout, 20, nil).HasError())
+	assert.False(t, r.SetWeight(tc.Rollout, 30, nil).HasError())
+	assert.Equal(t, 2, serviceGets())
+
+	// a Service deleted since is reported once the Services found are no longer trusted
+	defer func(ttl time.Duration) { servicesValidationTTL = ttl }(servicesValidationTTL)
+	assert.NoError(t, kubeClient.CoreV1().Services("gloo-mesh").Delete(context.Background(), "canary-v2", metav1.DeleteOptions{}))
+	kubeClient.ClearActions()
+	assert.False(t, r.SetWeight(tc.Rollout, 30, nil).HasError())
+	assert.Equal(t, 0, serviceGets())
+	servicesValidationTTL = 0
+	assert.Contains(t, r.SetWeight(tc.Rollout, 30, nil).ErrorString, "canaryService canary-v2 not found in namespace gloo-mesh")
+	assert.Equal(t, 2, serviceGets())
+}
+
+func TestPluginConfigValidation(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.KubeClient = k8sfake.NewSimpleClientset(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "stable", Namespace: "gloo-mesh"}})
+	})
+	// every problem is reported at once
+	tc.setPluginConfig(`{"routeTableSelecter":{"name":"demo"},"routeSelector":{"lables":{"route":"demo"},"labels":{"bad key!":"demo"}},"driftAction":"ignore"}`)
+	assert.Equal(t, `invalid solo-io/glooplatform plugin config: unknown field "routeSelector.lables"; unknown field "routeTableSelecter"; routeTableSelector is required; `+
+		`routeSelector.labels: key "bad key!": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]'); `+
+		`invalid driftAction "ignore": must be one of reapply, verify or fail; canaryService canary not found in namespace gloo-mesh`, r.SetWeight(tc.Rollout, 10, nil).ErrorString)
+
+	// a selector without name or labels would match every RouteTable in the namespace
+	tc.setPluginConfig(`{"routeTableSelector":{"namespace":"gloo-mesh"}}`)
+	assert.Contains(t, r.SetWeight(tc.Rollout, 10, nil).ErrorString, "routeTableSelector: name or labels is required")
+
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"maxTrafficWeight":-100}`)
+	assert.Contains(t, r.SetWeight(tc.Rollout, 10, nil).ErrorString, "invalid maxTrafficWeight -100: must be positive")
+
+	// forcing ownership only applies to server-side apply
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"default","namespace":"gloo-mesh"},"forceOwnership":true}`)
+	assert.Contains(t, r.SetWeight(tc.Rollout, 10, nil).ErrorString, "forceOwnership requires serverSideApply")
+
+	// type errors are reported as is
+	tc.setPluginConfig(`{"routeTableSelector":{"name":5}}`)
+	assert.Contains(t, r.SetWeight(tc.Rollout, 10, nil).ErrorString, "cannot unmarshal number into Go struct field GlooPlatformAPITrafficRouting.routeTableSelector.name of type string")
+
+	// matching field names is case insensitive, like encoding/json
+	tc.setPluginConfig(`{"RouteTableSelector":{"name":"default","namespace":"gloo-mesh"}}`)
+	_, err := r.KubeClient.CoreV1().Services("gloo-mesh").Create(context.Background(), &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "gloo-mesh"}}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.False(t, r.SetWeight(tc.Rollout, 10, nil).HasError())
+}
+
+func TestConfigJSONSchema(t *testing.T) {
+	schema, err := ConfigJSONSchema()
+	assert.NoError(t, err)
+	published, err := os.ReadFile("../../schema/plugin-config.schema.json")
+	assert.NoError(t, err)
+	assert.Equal(t, string(published), string(schema), "the published schema is out of date; run make schema")
+}
+
+func TestConfigDefaults(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "glooplatform-plugin-defaults", Namespace: "argo-rollouts"},
+		Data: map[string]string{
+			ConfigDefaultsKey: `
+routeTableSelector:
+  namespace: gloo-mesh
+  labels:
+    app: demo
+dryRun: true
+`,
+			ConfigDefaultsNamespacePrefix + "gloo-mesh": `
+routeTableSelector:
+  labels:
+    team: a
+driftAction: verify
+`,
+		},
+	}
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.KubeClient = k8sfake.NewSimpleClientset(cm)
+		r.Settings = &config.Settings{ConfigDefaults: &config.ObjectRef{Name: cm.Name, Namespace: cm.Namespace}}
+	})
+
+	// the Rollout config is merged over the namespace defaults, which are merged over the cluster-wide defaults
+	tc.setPluginConfig(`{"routeTableSelector":{"labels":{"app":"demo-v2"}},"dryRun":false}`)
+	cfg, err := r.getPluginConfig(tc.Rollout)
+	assert.NoError(t, err)
+	assert.Equal(t, &DumbObjectSelector{Namespace: "gloo-mesh", Labels: map[string]string{"app": "demo-v2", "team": "a"}}, cfg.RouteTableSelector)
+	assert.Equal(t, config.DriftActionVerify, cfg.DriftAction)
+	assert.False(t, *cfg.DryRun)
+
+	// null removes a default; Rollouts in other namespaces only get the cluster-wide defaults
+	tc.Rollout.Namespace = "other"
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"demo","labels":null}}`)
+	cfg, err = r.getPluginConfig(tc.Rollout)
+	assert.NoError(t, err)
+	assert.Equal(t, &DumbObjectSelector{Namespace: "gloo-mesh", Name: "demo"}, cfg.RouteTableSelector)
+	assert.Equal(t, "", cfg.DriftAction)
+	assert.True(t, *cfg.DryRun)
+
+	_, err = ParseConfigDefaults(map[string]string{
+		ConfigDefaultsKey: `routeTableSelecter: {}`,
+		"team-a":          `dryRun: true`,
+	})
+	assert.EqualError(t, err, `invalid plugin config defaults: defaults: unknown field "routeTableSelecter"; unknown key "team-a": must be defaults or namespace.<namespace>`)
+}
+
+func TestSelectorTemplates(t *testing.T) {
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	tc.Rollout.Labels = map[string]string{"app": "checkout"}
+
+	r := tc.newPlugin(t, nil)
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"{{ .Rollout.Name }}-routes","namespace":"{{ .Rollout.Namespace }}"},"routeSelector":{"labels":{"app":"{{ index .Rollout.Labels \"app\" }}"}}}`)
+	cfg, err := r.getPluginConfig(tc.Rollout)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo-routes", cfg.RouteTableSelector.Name)
+	assert.Equal(t, "gloo-mesh", cfg.RouteTableSelector.Namespace)
+	assert.Equal(t, map[string]string{"app": "checkout"}, cfg.RouteSelector.Labels)
+
+	// rendered values are validated like any other
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"{{ .Rollout.Nmae }}","labels":{"app":"{{ index .Rollout.Labels \"team\" }}"}},"routeSelector":{"name":"{{ .Rollout.Name"}}`)
+	_, err = r.getPluginConfig(tc.Rollout)
+	assert.EqualError(t, err, `invalid solo-io/glooplatform plugin config: `+
+		`template: routeTableSelector.name:1:11: executing "routeTableSelector.name" at <.Rollout.Nmae>: can't evaluate field Nmae in type *v1alpha1.Rollout; `+
+		`routeTableSelector.labels.app: template "{{ index .Rollout.Labels \"team\" }}" rendered an empty value; `+
+		`template: routeSelector.name:1: unclosed action`)
+
+	// fields whose templates did not render are not validated as literals, the others still are
+	tc.setPluginConfig(`{"routeTableSelector":{"name":"{{ .Rollout.Nmae }}","namespace":"Gloo_Mesh","labels":{"app":"{{ .Rollout.Name }}!"}}}`)
+	_, err = r.getPluginConfig(tc.Rollout)
+	assert.ErrorContains(t, err, `template: routeTableSelector.name:1:11: executing "routeTableSelector.name" at <.Rollout.Nmae>: can't evaluate field Nmae in type *v1alpha1.Rollout; `+
+		`routeTableSelector.namespace "Gloo_Mesh": a lowercase RFC 1123 label must consist of`)
+	assert.ErrorContains(t, err, `routeTableSelector.labels: value "demo!" of app: a valid label must be`)
+	assert.NotContains(t, err.Error(), `routeTableSelector.name "`)
+}
+
+func TestRPCSurface(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newRollout := func(name, namespace, pluginConfig string) *v1alpha1.Rollout {
+		return &v1alpha1.Rollout{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: v1alpha1.RolloutSpec{Strategy: v1alpha1.RolloutStrategy{Canary: &v1alpha1.CanaryStrategy{
+				StableService: name + "-stable",
+				CanaryService: name + "-canary",
+				TrafficRouting: &v1alpha1.RolloutTrafficRouting{
+					Plugins: map[string]json.RawMessage{PluginName: json.RawMessage(pluginConfig)},
+				},
+			}}},
+		}
+	}
+	newRouteTable := func(name, namespace, app string) *networkv2.RouteTable {
+		return &networkv2.RouteTable{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": app}},
+			Spec: networkv2.RouteTableSpec{Http: []*networkv2.HTTPRoute{{
+				Name: app,
+				ActionType: &networkv2.HTTPRoute_ForwardTo{ForwardTo: &networkv2.ForwardToAction{
+					Destinations: []*solov2.DestinationReference{{
+						RefKind: &solov2.DestinationReference_Ref{Ref: &solov2.ObjectReference{Name: app + "-stable", Namespace: namespace}},
+					}},
+				}},
+			}}},
+		}
+	}
+	weights := func(rt *networkv2.RouteTable) map[string]uint32 {
+		m := map[string]uint32{}
+		for _, dest := range rt.Spec.Http[0].GetForwardTo().Destinations {
+			m[dest.GetRef().GetName()] = dest.Weight
+		}
+		return m
+	}
+
+	// the RouteTables of each Rollout are selected by label within the Rollout namespace
+	checkout := newRollout("checkout", "shop", `{"routeTableSelector":{"labels":{"app":"{{ .Rollout.Name }}"}}}`)
+	search := newRollout("search", "web", `{"routeTableSelector":{"labels":{"app":"search"}},"driftAction":"verify"}`)
+	rts := []*networkv2.RouteTable{
+		newRouteTable("east", "shop", "checkout"),
+		newRouteTable("west", "shop", "checkout"),
+		newRouteTable("edge", "web", "search"),
+		// not selected: another namespace
+		newRouteTable("east", "other", "checkout"),
+	}
+	var glooObjs []k8sclient.Object
+	for _, rt := range rts {
+		glooObjs = append(glooObjs, rt.DeepCopy())
+	}
+
+	r := newTestPlugin(checkout, glooObjs...)
+	r.KubeClient = k8sfake.NewSimpleClientset(rolloutServices(checkout, search)...)
+	r.RolloutsClient = rolloutsfake.NewSimpleClientset(checkout, search)
+	p, closeCh, err := serveTestPlugin(ctx, r)
+	assert.NoError(t, err)
+
+	assert.False(t, p.InitPlugin().HasError())
+	assert.Equal(t, Type, p.Type())
+	assert.False(t, p.UpdateHash(checkout, "canary-hash", "stable-hash", nil).HasError())
+
+	assert.False(t, p.SetWeight(checkout, 10, nil).HasError())
+	assert.False(t, p.SetWeight(search, 30, nil).HasError())
+	for _, name := range []string{"east", "west"} {
+		rt := getRouteTable(t, r, name, "shop")
+		assert.Equal(t, map[string]uint32{"checkout-stable": 90, "checkout-canary": 10}, weights(rt), name)
+		assert.Equal(t, `{"checkout":"shop/checkout"}`, rt.Annotations[AnnotationRouteOwners], name)
+	}
+	edge := getRouteTable(t, r, "edge", "web")
+	assert.Equal(t, map[string]uint32{"search-stable": 70, "search-canary": 30}, weights(edge))
+	assert.Equal(t, `{"search":"web/search"}`, edge.Annotations[AnnotationRouteOwners])
+	assert.True(t, rts[3].Spec.Equal(&getRouteTable(t, r, "east", "other").Spec))
+
+	verified, rpcErr := p.VerifyWeight(search, 30, nil)
+	assert.False(t, rpcErr.HasError())
+	assert.Equal(t, pluginTypes.Verified, verified)
+	editRouteTable(t, r, "edge", "web", func(rt *networkv2.RouteTable) {
+		rt.Spec.Http[0].GetForwardTo().Destinations[0].Weight = 100
+		rt.Spec.Http[0].GetForwardTo().Destinations[1].Weight = 0
+	})
+	verified, rpcErr = p.VerifyWeight(search, 30, nil)
+	assert.False(t, rpcErr.HasError())
+	assert.Equal(t, pluginTypes.NotVerified, verified)
+
+	// header and mirror routes are not supported and leave the RouteTables untouched
+	resourceVersion := getRouteTable(t, r, "east", "shop").ResourceVersion
+	assert.False(t, p.SetHeaderRoute(checkout, &v1alpha1.SetHeaderRoute{Name: "header"}).HasError())
+	assert.False(t, p.SetMirrorRoute(checkout, &v1alpha1.SetMirrorRoute{Name: "mirror"}).HasError())
+	assert.Equal(t, resourceVersion, getRouteTable(t, r, "east", "shop").ResourceVersion)
+
+	// checkout is promoted, search is aborted; the routes of both are released. Argo Rollouts sets the weight of a
+	// fully promoted Rollout to 0 after removing its managed routes, which does not claim them again
+	assert.False(t, p.RemoveManagedRoutes(checkout).HasError())
+	assert.False(t, p.SetWeight(checkout, 0, nil).HasError())
+	assert.False(t, p.RemoveManagedRoutes(search).HasError())
+	for _, rt := range []*networkv2.RouteTable{getRouteTable(t, r, "east", "shop"), getRouteTable(t, r, "west", "shop")} {
+		assert.Equal(t, map[string]uint32{"checkout-stable": 100, "checkout-canary": 0}, weights(rt), rt.Name)
+		assert.NotContains(t, rt.Annotations, AnnotationRouteOwners, rt.Name)
+	}
+	assert.NotContains(t, getRouteTable(t, r, "edge", "web").Annotations, AnnotationRouteOwners)
+
+	cancel()
+	<-closeCh
+}
+
+func TestGetClient(t *testing.T) {
+	// each client set does discovery against the API server when it is created
+	var created int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/api":
+			atomic.AddInt32(&created, 1)
+			fmt.Fprint(w, `{"kind":"APIVersions","versions":[]}`)
+		case "/apis":
+			fmt.Fprint(w, `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	kubeConfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters: [{name: mgmt, cluster: {server: %q}}]
+users: [{name: mgmt, user: {}}]
+contexts: [{name: mgmt, context: {cluster: mgmt, user: mgmt}}]
+current-context: mgmt
+`, server.URL)
+
+	dir, outside := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "mgmt"), []byte(kubeConfig), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "mgmt"), []byte(kubeConfig), 0o600))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "mgmt"), filepath.Join(dir, "escape")))
+
+	tc := loadTestCase(t, "10-basic-canary.yaml")
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mgmt", Namespace: "gloo-mesh", ResourceVersion: "1"},
+		Data:       map[string][]byte{"kubeconfig": []byte(kubeConfig)},
+	}
+	r := tc.newPlugin(t, func(r *RpcPlugin) {
+		r.KubeClient = k8sfake.NewSimpleClientset(append(rolloutServices(tc.Rollout), secret)...)
+		r.Settings = &config.Settings{KubeConfigDir: dir}
+	})
+	getClient := func(mc *ManagementClusterRef) (gloo.NetworkV2ClientSet, error) {
+		return r.getClient(context.Background(), tc.Rollout, &GlooPlatformAPITrafficRouting{ManagementCluster: mc})
+	}
+
+	// without a managementCluster, the plugin-wide client is used
+	client, err := getClient(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, r.Client, client)
+
+	// client sets are cached per kubeconfig file
+	client, err = getClient(&ManagementClusterRef{KubeConfigPath: "mgmt"})
+	assert.NoError(t, err)
+	cached, err := getClient(&ManagementClusterRef{KubeConfigPath: filepath.Join(dir, "mgmt")})
+	assert.NoError(t, err)
+	assert.Equal(t, client, cached)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created))
+
+	// and per Secret, until the Secret changes
+	client, err = getClient(&ManagementClusterRef{KubeConfigSecretRef: &SecretKeyRef{Name: "mgmt"}})
+	assert.NoError(t, err)
+	cached, err = getClient(&ManagementClusterRef{KubeConfigSecretRef: &SecretKeyRef{Name: "mgmt"}})
+	assert.NoError(t, err)
+	assert.Equal(t, client, cached)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&created))
+	secret.ResourceVersion = "2"
+	_, err = r.KubeClient.CoreV1().Secrets("gloo-mesh").Update(context.Background(), secret, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	rotated, err := getClient(&ManagementClusterRef{KubeConfigSecretRef: &SecretKeyRef{Name: "mgmt"}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, client, rotated)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&created))
+
+	// kubeconfig files outside of the kubeconfig directory are rejected, even through a symlink
+	_, err = getClient(&ManagementClusterRef{KubeConfigPath: "../" + filepath.Base(outside) + "/mgmt"})
+	assert.ErrorContains(t, err, "is not within "+dir)
+	_, err = getClient(&ManagementClusterRef{KubeConfigPath: filepath.Join(outside, "mgmt")})
+	assert.ErrorContains(t, err, "is not within "+dir)
+	_, err = getClient(&ManagementClusterRef{KubeConfigPath: "escape"})
+	assert.ErrorContains(t, err, "is not within "+dir)
+	_, err = getClient(&ManagementClusterRef{KubeConfigPath: "."})
+	assert.ErrorContains(t, err, "is not within "+dir)
+
+	// and all of them are without a kubeconfig directory
+	r.Settings.KubeConfigDir = ""
+	_, err = getClient(&ManagementClusterRef{KubeConfigPath: "mgmt"})
+	assert.EqualError(t, err, "managementCluster: kubeConfigPath is not allowed unless GLOO_PLUGIN_KUBECONFIG_DIR is set")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&created))
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/tracing"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/argoproj/argo-rollouts/utils/annotations"
+	pluginTypes "github.com/argoproj/argo-rollouts/utils/plugin/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the span of a plugin call made for rollout
+func startSpan(ctx context.Context, method string, rollout *v1alpha1.Rollout, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if rollout != nil {
+		attrs = append(attrs,
+			attribute.String("rollout.namespace", rollout.Namespace),
+			attribute.String("rollout.name", rollout.Name),
+			attribute.String("rollout.revision", rollout.Annotations[annotations.RevisionAnnotation]),
+		)
+	}
+	return tracing.Tracer().Start(ctx, method, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span of a plugin call, marking it failed if the call returned rpcErr
+func endSpan(span trace.Span, rpcErr pluginTypes.RpcError) {
+	var err error
+	if rpcErr.HasError() {
+		err = errors.New(rpcErr.ErrorString)
+	}
+	tracing.End(span, err)
+}
+
+// matchedRouteTablesAttribute lists the matched RouteTables as namespace/name
+func matchedRouteTablesAttribute(matchedRts []*GlooMatchedRouteTable) attribute.KeyValue {
+	var names []string
+	for _, rt := range matchedRts {
+		names = append(names, fmt.Sprintf("%s/%s", rt.RouteTable.Namespace, rt.RouteTable.Name))
+	}
+	return attribute.StringSlice("routetables", names)
+}
+
+// routeWeightsAttribute lists the stable and canary weights of the matched routes of rt
+func routeWeightsAttribute(rt *GlooMatchedRouteTable) attribute.KeyValue {
+	var weights []string
+	for _, w := range rt.routeWeights() {
+		weights = append(weights, fmt.Sprintf("%s: stable=%d canary=%d", w.Route, w.Stable, w.Canary))
+	}
+	return attribute.StringSlice("weights", weights)
+}
@@ -0,0 +1,248 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/argoproj-labs/rollouts-plugin-trafficrouter-glooplatform/pkg/config"
+	"github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// configError reports every problem found with the plugin config of a Rollout in a single error
+func configError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid %s plugin config: %s", PluginName, strings.Join(problems, "; "))
+}
+
+// decodePluginConfig decodes a plugin config, rejecting unknown fields. Every unknown field is reported, and the
+// config is still decoded (ignoring them) so that it can be validated as well; the config is nil if it could not
+// be decoded at all.
+func decodePluginConfig(data []byte) (*GlooPlatformAPITrafficRouting, []string) {
+	glooplatformConfig := &GlooPlatformAPITrafficRouting{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(glooplatformConfig)
+	if err == nil {
+		return glooplatformConfig, nil
+	}
+
+	var raw interface{}
+	if json.Unmarshal(data, &raw) != nil {
+		return nil, []string{err.Error()}
+	}
+	var problems []string
+	for _, field := range unknownFields("", raw, reflect.TypeOf(glooplatformConfig)) {
+		problems = append(problems, fmt.Sprintf("unknown field %q", field))
+	}
+	if len(problems) == 0 {
+		return nil, []string{err.Error()}
+	}
+
+	glooplatformConfig = &GlooPlatformAPITrafficRouting{}
+	if err := json.Unmarshal(data, glooplatformConfig); err != nil {
+		return nil, append(problems, err.Error())
+	}
+	return glooplatformConfig, problems
+}
+
+// unknownFields returns the paths of the fields of v that t does not have, matching field names like encoding/json
+func unknownFields(path string, v interface{}, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var unknown []string
+	for _, k := range keys {
+		fieldPath := k
+		if path != "" {
+			fieldPath = path + "." + k
+		}
+		field, ok := jsonField(t, k)
+		if !ok {
+			unknown = append(unknown, fieldPath)
+			continue
+		}
+		unknown = append(unknown, unknownFields(fieldPath, obj[k], field.Type)...)
+	}
+	return unknown
+}
+
+// jsonField returns the field of struct type t decoded from the JSON field name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); strings.EqualFold(jsonName(f), name) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// jsonName returns the JSON field name of f
+func jsonName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// validate returns every problem with the plugin config that can be found without talking to the API server. The
+// selector fields whose templates did not render, as returned by renderSelectors, are not validated.
+func (c *GlooPlatformAPITrafficRouting) validate(unrendered map[string]bool) []string {
+	var problems []string
+	if s := c.RouteTableSelector; s == nil {
+		problems = append(problems, "routeTableSelector is required")
+	} else {
+		if s.Name == "" && !unrendered["routeTableSelector.name"] && len(s.Labels) == 0 {
+			problems = append(problems, "routeTableSelector: name or labels is required")
+		}
+		if s.Name != "" && !unrendered["routeTableSelector.name"] {
+			for _, msg := range validation.IsDNS1123Subdomain(s.Name) {
+				problems = append(problems, fmt.Sprintf("routeTableSelector.name %q: %s", s.Name, msg))
+			}
+		}
+		if s.Namespace != "" && !unrendered["routeTableSelector.namespace"] {
+			for _, msg := range validation.IsDNS1123Label(s.Namespace) {
+				problems = append(problems, fmt.Sprintf("routeTableSelector.namespace %q: %s", s.Namespace, msg))
+			}
+		}
+		problems = append(problems, validateLabels("routeTableSelector.labels", s.Labels, unrendered)...)
+	}
+	if s := c.RouteSelector; s != nil {
+		problems = append(problems, validateLabels("routeSelector.labels", s.Labels, unrendered)...)
+	}
+	if mc := c.ManagementCluster; mc != nil {
+		if mc.KubeConfigPath != "" && mc.KubeConfigSecretRef != nil {
+			problems = append(problems, "managementCluster: only one of kubeConfigPath and kubeConfigSecretRef may be specified")
+		}
+		if mc.KubeConfigSecretRef != nil && mc.KubeConfigSecretRef.Name == "" {
+			problems = append(problems, "managementCluster: kubeConfigSecretRef.name is required")
+		}
+	}
+	if c.MaxTrafficWeight < 0 {
+		problems = append(problems, fmt.Sprintf("invalid maxTrafficWeight %d: must be positive", c.MaxTrafficWeight))
+	}
+	if c.ForceOwnership && !c.ServerSideApply {
+		problems = append(problems, "forceOwnership requires serverSideApply")
+	}
+	if c.DriftAction != "" && !config.ValidDriftAction(c.DriftAction) {
+		problems = append(problems, fmt.Sprintf("invalid driftAction %q: must be one of %s, %s or %s", c.DriftAction, config.DriftActionReapply, config.DriftActionVerify, config.DriftActionFail))
+	}
+	return problems
+}
+
+func validateLabels(path string, labels map[string]string, unrendered map[string]bool) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, k := range keys {
+		for _, msg := range validation.IsQualifiedName(k) {
+			problems = append(problems, fmt.Sprintf("%s: key %q: %s", path, k, msg))
+		}
+		if unrendered[path+"."+k] {
+			continue
+		}
+		for _, msg := range validation.IsValidLabelValue(labels[k]) {
+			problems = append(problems, fmt.Sprintf("%s: value %q of %s: %s", path, labels[k], k, msg))
+		}
+	}
+	return problems
+}
+
+// servicesValidationTTL is how long the Services found by validateServices are trusted before they are looked up
+// again, so that a deleted Service is reported within that time
+var servicesValidationTTL = time.Minute
+
+// validatedServices records, per Rollout, the stable and canary Services found by validateServices, so that the
+// Services are only looked up again when the Rollout refers to other Services or servicesValidationTTL has passed
+type validatedServices struct {
+	mu sync.Mutex
+	// Rollout namespace/name -> stable and canary Service names and when they were found
+	services map[string]validatedServicesEntry
+}
+
+type validatedServicesEntry struct {
+	services    string
+	validatedAt time.Time
+}
+
+func (v *validatedServices) validated(rollout *v1alpha1.Rollout, services string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.services[rolloutKey(rollout)]
+	return ok && entry.services == services && time.Since(entry.validatedAt) < servicesValidationTTL
+}
+
+func (v *validatedServices) set(rollout *v1alpha1.Rollout, services string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.services == nil {
+		v.services = map[string]validatedServicesEntry{}
+	}
+	v.services[rolloutKey(rollout)] = validatedServicesEntry{services: services, validatedAt: time.Now()}
+}
+
+// validateServices returns a problem for each of the stable and canary Services of rollout that is not set or does
+// not exist. Without a Kubernetes client the Services are assumed to exist. Services found are not looked up again
+// until the Rollout refers to other Services or servicesValidationTTL has passed.
+func (r *RpcPlugin) validateServices(ctx context.Context, rollout *v1alpha1.Rollout) []string {
+	canary := rollout.Spec.Strategy.Canary
+	if canary == nil {
+		return nil
+	}
+	services := canary.StableService + "/" + canary.CanaryService
+	if r.validatedServices.validated(rollout, services) {
+		return nil
+	}
+
+	var problems []string
+	for _, svc := range []struct{ field, name string }{
+		{"stableService", canary.StableService},
+		{"canaryService", canary.CanaryService},
+	} {
+		if svc.name == "" {
+			problems = append(problems, fmt.Sprintf("%s of the Rollout is required", svc.field))
+			continue
+		}
+		if r.KubeClient == nil {
+			continue
+		}
+		_, err := r.KubeClient.CoreV1().Services(rollout.Namespace).Get(ctx, svc.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			problems = append(problems, fmt.Sprintf("%s %s not found in namespace %s", svc.field, svc.name, rollout.Namespace))
+		} else if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to get %s %s: %s", svc.field, svc.name, err))
+		}
+	}
+	if len(problems) == 0 {
+		r.validatedServices.set(rollout, services)
+	}
+	return problems
+}
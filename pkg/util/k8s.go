@@ -1,6 +1,7 @@
 package util
 
 import (
+	rolloutsclientset "github.com/argoproj/argo-rollouts/pkg/client/clientset/versioned"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -32,6 +33,18 @@ func GetKubernetesClient() (*kubernetes.Clientset, error) {
 	return cs, nil
 }
 
+func GetRolloutsClient() (*rolloutsclientset.Clientset, error) {
+	cfg, err := GetKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := rolloutsclientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
 func GetDynamicClient() (*dynamic.DynamicClient, error) {
 	cfg, err := GetKubeConfig()
 	if err != nil {
@@ -43,3 +56,19 @@ func GetDynamicClient() (*dynamic.DynamicClient, error) {
 	}
 	return dynamicClient, nil
 }
+
+func GetKubeConfigFromFile(path string) (*rest.Config, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func GetKubeConfigFromBytes(data []byte) (*rest.Config, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}